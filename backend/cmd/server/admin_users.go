@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/middleware"
+)
+
+// checkCanManageUser stops delegated admins from taking over accounts that
+// hold permissions they do not have themselves, e.g. a helpdesk user
+// resetting the root password.
+func checkCanManageUser(ctx context.Context, c *fiber.Ctx, targetID string) error {
+	actor := c.Locals("user").(*middleware.UserClaims)
+	if actor.UserID == targetID {
+		return nil
+	}
+
+	_, targetPermissions, err := loadUserAccess(ctx, queries, targetID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load permissions")
+	}
+	held := make(map[string]bool, len(actor.Permissions))
+	for _, p := range actor.Permissions {
+		held[p] = true
+	}
+	for _, p := range targetPermissions {
+		if !held[p] {
+			return fiber.NewError(fiber.StatusForbidden, "Cannot manage a user with permissions you do not hold")
+		}
+	}
+	return nil
+}
+
+// @Summary Delete user
+// @Description Soft-delete a user and sign them out everywhere. The account can be restored until it is purged after USER_DELETION_RETENTION; auth logs are kept.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/users/{id} [delete]
+func deleteUserHandler(c *fiber.Ctx) error {
+	ctx := context.Background()
+	pgID := pgtype.Text{String: c.Params("id"), Valid: true}
+	user, err := queries.GetUserByID(ctx, pgID)
+	if err != nil || user.DeletedAt.Valid {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+	if rootEmail := os.Getenv("ROOT_USER_EMAIL"); rootEmail != "" && user.Email.String == rootEmail {
+		return fiber.NewError(fiber.StatusForbidden, "Cannot delete Root User")
+	}
+	if c.Locals("user").(*middleware.UserClaims).UserID == user.ID {
+		return fiber.NewError(fiber.StatusForbidden, "Cannot delete your own account")
+	}
+	if err := checkCanManageUser(ctx, c, user.ID); err != nil {
+		return err
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Tx Error")
+	}
+	defer tx.Rollback(ctx)
+	qtx := queries.WithTx(tx)
+
+	deletedAt := time.Now().UTC()
+	if err := qtx.SoftDeleteUser(ctx, pgID, pgtype.Timestamp{Time: deletedAt, Valid: true}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to delete user")
+	}
+	if err := revokeUserSessions(ctx, qtx, user.ID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to delete user")
+	}
+	recordAuthEvent(ctx, c, user.ID, "USER_DELETED", fiber.Map{"email": user.Email.String})
+
+	return c.JSON(fiber.Map{
+		"status":        "deleted",
+		"restore_until": deletedAt.Add(cfg.UserDeletionRetention),
+	})
+}
+
+// @Summary List deleted users
+// @Description List soft-deleted users that have not been purged yet
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param limit query int false "Limit" default(50)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} db.ListDeletedUsersRow
+// @Router /admin/users/deleted [get]
+func listDeletedUsersHandler(c *fiber.Ctx) error {
+	pgLimit := pgtype.Int8{Int64: int64(c.QueryInt("limit", 50)), Valid: true}
+	pgOffset := pgtype.Int8{Int64: int64(c.QueryInt("offset", 0)), Valid: true}
+
+	users, err := queries.ListDeletedUsers(context.Background(), pgLimit, pgOffset)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to list deleted users")
+	}
+	return c.JSON(users)
+}
+
+// @Summary Restore user
+// @Description Undo the deletion of a user within the retention period. The user has to sign in again.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/users/{id}/restore [post]
+func restoreUserHandler(c *fiber.Ctx) error {
+	ctx := context.Background()
+	userID := c.Params("id")
+	cutoff := time.Now().UTC().Add(-cfg.UserDeletionRetention)
+
+	restored, err := queries.RestoreUser(ctx, pgtype.Text{String: userID, Valid: true}, pgtype.Timestamp{Time: cutoff, Valid: true})
+	// Emails are only unique among users that are not deleted
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fiber.NewError(fiber.StatusConflict, "Another user has taken the email address of this user")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to restore user")
+	}
+	if restored == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "No deleted user with this ID within the retention period")
+	}
+	recordAuthEvent(ctx, c, userID, "USER_RESTORED", nil)
+
+	return c.JSON(fiber.Map{"status": "restored"})
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/audit"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+)
+
+const (
+	auditLogDefaultLimit = 100
+	auditLogMaxLimit     = 1000
+	// auditLogExportPage is the number of rows fetched per query while an
+	// export is streamed
+	auditLogExportPage = 1000
+)
+
+// AuditLogResponse is an auth_logs entry as returned by the audit log API
+type AuditLogResponse struct {
+	ID        string          `json:"id"`
+	Timestamp time.Time       `json:"timestamp"`
+	UserID    *string         `json:"user_id"`
+	Action    string          `json:"action"`
+	IPAddress string          `json:"ip_address"`
+	UserAgent string          `json:"user_agent"`
+	Metadata  json.RawMessage `json:"metadata"`
+}
+
+type AuditLogPage struct {
+	Data       []AuditLogResponse `json:"data"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// auditLogFilter holds the parsed query of an audit log request, as
+// arguments of ListAuditLogs
+type auditLogFilter struct {
+	userID    pgtype.Text
+	actions   []string
+	network   pgtype.Text
+	from      pgtype.Timestamp
+	to        pgtype.Timestamp
+	metadata  []byte
+	afterTime pgtype.Timestamp
+	afterID   pgtype.Text
+	limit     int
+}
+
+func newAuditLogResponse(row db.ListAuditLogsRow) AuditLogResponse {
+	resp := AuditLogResponse{
+		ID:        row.ID,
+		Timestamp: row.Timestamp.Time,
+		Action:    row.Action,
+		IPAddress: row.IpAddress.String,
+		UserAgent: row.UserAgent.String,
+		Metadata:  json.RawMessage(row.Metadata),
+	}
+	if row.UserID.Valid {
+		resp.UserID = &row.UserID.String
+	}
+	if row.Metadata == nil {
+		resp.Metadata = json.RawMessage("null")
+	}
+	return resp
+}
+
+// encodeAuditCursor points after the given row. Cursors are opaque to
+// clients: base64 of "<timestamp>|<id>".
+func encodeAuditCursor(row db.ListAuditLogsRow) string {
+	raw := row.Timestamp.Time.Format(time.RFC3339Nano) + "|" + row.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeAuditCursor(cursor string) (time.Time, string, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, "", false
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return t, id, true
+}
+
+// parseAuditLogFilter reads the filters, cursor and limit of the request
+func parseAuditLogFilter(c *fiber.Ctx) (*auditLogFilter, error) {
+	f := &auditLogFilter{limit: c.QueryInt("limit", auditLogDefaultLimit)}
+	if f.limit <= 0 || f.limit > auditLogMaxLimit {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and 1000")
+	}
+
+	if userID := c.Query("user_id"); userID != "" {
+		f.userID = pgtype.Text{String: userID, Valid: true}
+	}
+	for _, action := range strings.Split(c.Query("action"), ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			f.actions = append(f.actions, strings.ToUpper(action))
+		}
+	}
+
+	if ip := c.Query("ip"); ip != "" {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "ip must be an IP address or CIDR")
+		}
+		f.network = pgtype.Text{String: ip, Valid: true}
+	}
+
+	for name, ts := range map[string]*pgtype.Timestamp{"from": &f.from, "to": &f.to} {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fiber.NewError(fiber.StatusBadRequest, name+" must be an RFC 3339 timestamp")
+			}
+			*ts = pgtype.Timestamp{Time: t.UTC(), Valid: true}
+		}
+	}
+
+	// metadata.<key>=<value> matches entries whose metadata contains the
+	// key with that (string) value
+	metadata := map[string]string{}
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		if name, ok := strings.CutPrefix(string(key), "metadata."); ok && name != "" {
+			metadata[name] = string(value)
+		}
+	})
+	if len(metadata) > 0 {
+		f.metadata, _ = json.Marshal(metadata)
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		t, id, ok := decodeAuditCursor(cursor)
+		if !ok {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid cursor")
+		}
+		f.afterTime = pgtype.Timestamp{Time: t, Valid: true}
+		f.afterID = pgtype.Text{String: id, Valid: true}
+	}
+	return f, nil
+}
+
+// list fetches the next page and moves the filter's cursor past it
+func (f *auditLogFilter) list(ctx context.Context) ([]db.ListAuditLogsRow, error) {
+	rows, err := queries.ListAuditLogs(ctx,
+		f.userID,
+		f.actions,
+		f.network,
+		f.from,
+		f.to,
+		f.metadata,
+		f.afterTime,
+		f.afterID,
+		pgtype.Int8{Int64: int64(f.limit), Valid: true},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		f.afterTime = last.Timestamp
+		f.afterID = pgtype.Text{String: last.ID, Valid: true}
+	}
+	return rows, nil
+}
+
+// @Summary Search audit log
+// @Description Search auth_logs newest first. Filters can be combined; metadata.<key>=<value> matches metadata fields (e.g. metadata.provider=google). With format=csv or format=ndjson every matching entry is streamed as a download instead of a page.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json,text/csv,application/x-ndjson
+// @Param user_id query string false "User ID"
+// @Param action query string false "Actions, comma-separated (e.g. LOGIN,FAILED_LOGIN)"
+// @Param ip query string false "Client IP address or CIDR"
+// @Param from query string false "Start time, inclusive (RFC 3339)"
+// @Param to query string false "End time, exclusive (RFC 3339)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Page size, max 1000" default(100)
+// @Param format query string false "json, csv or ndjson" default(json)
+// @Success 200 {object} AuditLogPage
+// @Failure 400 {object} map[string]interface{}
+// @Router /admin/audit-logs [get]
+func listAuditLogsHandler(c *fiber.Ctx) error {
+	filter, err := parseAuditLogFilter(c)
+	if err != nil {
+		return err
+	}
+
+	switch format := c.Query("format", "json"); format {
+	case "json":
+	case "csv", "ndjson":
+		return exportAuditLogs(c, filter, format)
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "format must be json, csv or ndjson")
+	}
+
+	rows, err := filter.list(context.Background())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to list audit logs")
+	}
+
+	page := AuditLogPage{Data: make([]AuditLogResponse, 0, len(rows))}
+	for _, row := range rows {
+		page.Data = append(page.Data, newAuditLogResponse(row))
+	}
+	if len(rows) == filter.limit {
+		page.NextCursor = encodeAuditCursor(rows[len(rows)-1])
+	}
+	return c.JSON(page)
+}
+
+// exportAuditLogs streams every entry matching the filter, fetching it page
+// by page so that large extracts are never held in memory
+func exportAuditLogs(c *fiber.Ctx, filter *auditLogFilter, format string) error {
+	filter.limit = auditLogExportPage
+	filename := "audit-logs-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	if format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	recordAuthEvent(context.Background(), c, "", "AUDIT_LOG_EXPORT", fiber.Map{"format": format, "query": string(c.Request().URI().QueryString())})
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context ends when the handler returns, the stream
+		// outlives it
+		ctx := context.Background()
+		csvWriter := csv.NewWriter(w)
+		encoder := json.NewEncoder(w)
+		if format == "csv" {
+			csvWriter.Write([]string{"id", "timestamp", "user_id", "action", "ip_address", "user_agent", "metadata"})
+		}
+
+		for {
+			rows, err := filter.list(ctx)
+			if err != nil {
+				log.Printf("Audit log export failed: %v", err)
+				return
+			}
+			for _, row := range rows {
+				if format == "csv" {
+					csvWriter.Write([]string{
+						csvSafe(row.ID),
+						row.Timestamp.Time.Format(time.RFC3339Nano),
+						csvSafe(row.UserID.String),
+						csvSafe(row.Action),
+						csvSafe(row.IpAddress.String),
+						csvSafe(row.UserAgent.String),
+						csvSafe(string(row.Metadata)),
+					})
+				} else if err := encoder.Encode(newAuditLogResponse(row)); err != nil {
+					return
+				}
+			}
+			csvWriter.Flush()
+			// A failed flush means the client went away
+			if err := w.Flush(); err != nil || len(rows) < filter.limit {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// @Summary Verify audit log
+// @Description Walk the audit log hash chain and check every entry, link and signed checkpoint. Any deleted, inserted or modified entry is listed under problems.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} audit.Report
+// @Router /admin/audit-logs/verify [get]
+func verifyAuditLogHandler(c *fiber.Ctx) error {
+	ctx := context.Background()
+	report, err := audit.Verify(ctx, queries, auditChain.PublicKey())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify audit log")
+	}
+	recordAuthEvent(ctx, c, "", "AUDIT_LOG_VERIFY", fiber.Map{
+		"valid":         report.Valid,
+		"last_seq":      report.LastSeq,
+		"problem_count": report.ProblemCount,
+	})
+	return c.JSON(report)
+}
+
+// csvSafe stops spreadsheet applications from evaluating exported values as
+// formulas. Every text column goes through it: besides the user agent, older
+// entries may hold whatever a client sent as its IP.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+)
+
+var auditEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newAuditServer serves /audit-logs over entries, which ListAuditLogs
+// filters like the query does
+func newAuditServer(t *testing.T, entries []db.ListAuditLogsRow) *testServer {
+	s := newTestServer(t)
+	s.app.Get("/audit-logs", listAuditLogsHandler)
+
+	// Same conditions and order as the query
+	s.db.Handle("ListAuditLogs", func(args ...any) (any, error) {
+		userID, actions, network := args[0].(pgtype.Text), args[1].([]string), args[2].(pgtype.Text)
+		from, to, metadata := args[3].(pgtype.Timestamp), args[4].(pgtype.Timestamp), args[5].([]byte)
+		afterTime, afterID, limit := args[6].(pgtype.Timestamp), args[7].(pgtype.Text), args[8].(pgtype.Int8)
+
+		sorted := slices.Clone(entries)
+		slices.SortFunc(sorted, func(a, b db.ListAuditLogsRow) int {
+			if c := b.Timestamp.Time.Compare(a.Timestamp.Time); c != 0 {
+				return c
+			}
+			switch {
+			case a.ID > b.ID:
+				return -1
+			case a.ID < b.ID:
+				return 1
+			}
+			return 0
+		})
+
+		var rows []db.ListAuditLogsRow
+		for _, row := range sorted {
+			ts := row.Timestamp.Time
+			switch {
+			case userID.Valid && row.UserID != userID,
+				actions != nil && !slices.Contains(actions, row.Action),
+				network.Valid && !inNetwork(row.IpAddress.String, network.String),
+				from.Valid && ts.Before(from.Time),
+				to.Valid && !ts.Before(to.Time),
+				metadata != nil && !containsMetadata(row.Metadata, metadata),
+				afterTime.Valid && (ts.After(afterTime.Time) || ts.Equal(afterTime.Time) && row.ID >= afterID.String):
+				continue
+			}
+			if int64(len(rows)) == limit.Int64 {
+				break
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	})
+	return s
+}
+
+func inNetwork(ip, network string) bool {
+	if _, ipNet, err := net.ParseCIDR(network); err == nil {
+		return ipNet.Contains(net.ParseIP(ip))
+	}
+	return net.ParseIP(ip).Equal(net.ParseIP(network))
+}
+
+func containsMetadata(metadata, want []byte) bool {
+	var got, fields map[string]any
+	if json.Unmarshal(metadata, &got) != nil || json.Unmarshal(want, &fields) != nil {
+		return false
+	}
+	for key, value := range fields {
+		if got[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func auditEntry(id, userID, action, ip string, minutes int, metadata string) db.ListAuditLogsRow {
+	row := db.ListAuditLogsRow{
+		ID:        id,
+		UserID:    pgtype.Text{String: userID, Valid: userID != ""},
+		Action:    action,
+		IpAddress: pgtype.Text{String: ip, Valid: true},
+		Timestamp: pgtype.Timestamp{Time: auditEpoch.Add(time.Duration(minutes) * time.Minute), Valid: true},
+	}
+	if metadata != "" {
+		row.Metadata = []byte(metadata)
+	}
+	return row
+}
+
+// auditLogs gets a page of the audit log for query
+func (s *testServer) auditLogs(t *testing.T, query url.Values) (int, AuditLogPage) {
+	t.Helper()
+	resp, err := s.app.Test(httptest.NewRequest(fiber.MethodGet, "/audit-logs?"+query.Encode(), nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var page AuditLogPage
+	if resp.StatusCode == fiber.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(body, &page); err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+	}
+	return resp.StatusCode, page
+}
+
+func pageIDs(page AuditLogPage) []string {
+	ids := []string{}
+	for _, entry := range page.Data {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+// TestAuditLogPagination pages through entries two at a time, including two
+// entries sharing a timestamp across a page boundary
+func TestAuditLogPagination(t *testing.T) {
+	s := newAuditServer(t, []db.ListAuditLogsRow{
+		auditEntry("a", "alice", "LOGIN", "10.0.0.1", 1, ""),
+		auditEntry("b", "alice", "LOGIN", "10.0.0.1", 2, ""),
+		auditEntry("c", "bob", "LOGIN", "10.0.0.2", 3, ""),
+		auditEntry("d", "bob", "LOGOUT", "10.0.0.2", 3, ""),
+		auditEntry("e", "alice", "LOGOUT", "10.0.0.1", 4, ""),
+	})
+
+	var got []string
+	query := url.Values{"limit": {"2"}}
+	for pages := 1; ; pages++ {
+		status, page := s.auditLogs(t, query)
+		if status != fiber.StatusOK {
+			t.Fatalf("page %d: got %d, want %d", pages, status, fiber.StatusOK)
+		}
+		got = append(got, pageIDs(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		if pages == 5 {
+			t.Fatal("pagination does not end")
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	if want := []string{"e", "d", "c", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAuditLogFilters(t *testing.T) {
+	s := newAuditServer(t, []db.ListAuditLogsRow{
+		auditEntry("a", "alice", "LOGIN", "10.0.0.1", 1, `{"provider": "google"}`),
+		auditEntry("b", "alice", "FAILED_LOGIN", "10.0.1.1", 2, ""),
+		auditEntry("c", "bob", "LOGIN", "192.168.0.1", 3, `{"provider": "github"}`),
+		auditEntry("d", "", "IP_LOCKED", "10.0.0.1", 4, ""),
+	})
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  []string
+	}{
+		{"none", url.Values{}, []string{"d", "c", "b", "a"}},
+		{"user", url.Values{"user_id": {"alice"}}, []string{"b", "a"}},
+		{"actions", url.Values{"action": {"login, ip_locked"}}, []string{"d", "c", "a"}},
+		{"ip", url.Values{"ip": {"10.0.0.1"}}, []string{"d", "a"}},
+		{"network", url.Values{"ip": {"10.0.0.0/16"}}, []string{"d", "b", "a"}},
+		{"from and to", url.Values{"from": {auditEpoch.Add(2 * time.Minute).Format(time.RFC3339)}, "to": {auditEpoch.Add(4 * time.Minute).Format(time.RFC3339)}}, []string{"c", "b"}},
+		{"metadata", url.Values{"metadata.provider": {"google"}}, []string{"a"}},
+		{"combined", url.Values{"user_id": {"alice"}, "action": {"LOGIN"}}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, page := s.auditLogs(t, tt.query)
+			if status != fiber.StatusOK {
+				t.Fatalf("got %d, want %d", status, fiber.StatusOK)
+			}
+			if got := pageIDs(page); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditLogInvalidQuery(t *testing.T) {
+	s := newAuditServer(t, nil)
+	for _, query := range []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"1001"}},
+		{"ip": {"10.0.0"}},
+		{"from": {"yesterday"}},
+		{"cursor": {"not a cursor"}},
+		{"format": {"xml"}},
+	} {
+		if status, _ := s.auditLogs(t, query); status != fiber.StatusBadRequest {
+			t.Errorf("%s: got %d, want %d", query.Encode(), status, fiber.StatusBadRequest)
+		}
+	}
+}
+
+// TestAuditLogExportCSV exports entries holding formulas in every text
+// column, none of which may reach the CSV unescaped
+func TestAuditLogExportCSV(t *testing.T) {
+	entry := auditEntry("=1+1", "+user", "@SUM(A1)", "-2+3", 0, `{"a": "b"}`)
+	entry.UserAgent = pgtype.Text{String: "=HYPERLINK(\"https://evil.example\")", Valid: true}
+	s := newAuditServer(t, []db.ListAuditLogsRow{entry})
+
+	resp, err := s.app.Test(httptest.NewRequest(fiber.MethodGet, "/audit-logs?format=csv", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want a header and one entry", len(records))
+	}
+	for i, value := range records[1] {
+		if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+			t.Errorf("%s: %q is not escaped", records[0][i], value)
+		}
+	}
+}
+
+func TestAuditIP(t *testing.T) {
+	for ip, want := range map[string]string{
+		"192.0.2.1":                 "192.0.2.1",
+		"2001:DB8:0:0:0:0:0:1":      "2001:db8::1",
+		"":                          "",
+		"192.0.2.1, 198.51.100.1":   "",
+		"<script>alert(1)</script>": "",
+	} {
+		if got := auditIP(ip); got != want {
+			t.Errorf("auditIP(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Failed password logins are counted per email address, whether or not an
+// account exists (so lockouts do not reveal which addresses are registered),
+// and per client IP. Every failure doubles the wait before the next attempt;
+// reaching the threshold locks the email or IP for LOGIN_LOCKOUT_DURATION.
+// A lockout only blocks password logins, existing sessions keep working.
+// Wrong service client credentials at /auth/introspect are counted per IP
+// as well, apart from logins.
+
+func emailAttemptKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+func serviceClientAttemptKey(ip string) string {
+	return "client-ip:" + ip
+}
+
+// checkLoginBlocked rejects a login attempt while the email or the client IP
+// is backing off or locked out
+func checkLoginBlocked(ctx context.Context, c *fiber.Ctx, email string) error {
+	now := time.Now().UTC()
+	blockedUntil, err := queries.GetLoginBlockedUntil(ctx,
+		pgtype.Text{String: emailAttemptKey(email), Valid: true},
+		pgtype.Text{String: ipAttemptKey(c.IP()), Valid: true},
+		pgtype.Timestamp{Time: now, Valid: true},
+	)
+	if err != nil {
+		// Fail open: a broken counter must not lock everybody out
+		log.Printf("Failed to check login back-off: %v", err)
+		return nil
+	}
+	return blockedError(c, blockedUntil, now, "Too many failed login attempts, try again later")
+}
+
+// checkServiceClientBlocked rejects client authentication while the client
+// IP is backing off or locked out after wrong credentials
+func checkServiceClientBlocked(ctx context.Context, c *fiber.Ctx) error {
+	now := time.Now().UTC()
+	key := pgtype.Text{String: serviceClientAttemptKey(c.IP()), Valid: true}
+	blockedUntil, err := queries.GetLoginBlockedUntil(ctx, key, key, pgtype.Timestamp{Time: now, Valid: true})
+	if err != nil {
+		log.Printf("Failed to check client authentication back-off: %v", err)
+		return nil
+	}
+	return blockedError(c, blockedUntil, now, "Too many failed client authentication attempts, try again later")
+}
+
+// blockedError is the 429 answer to attempts made before blockedUntil
+func blockedError(c *fiber.Ctx, blockedUntil pgtype.Timestamp, now time.Time, msg string) error {
+	if !blockedUntil.Valid {
+		return nil
+	}
+	retryAfter := int(math.Ceil(blockedUntil.Time.Sub(now).Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return fiber.NewError(fiber.StatusTooManyRequests, msg)
+}
+
+// recordLoginFailure counts a failed login and blocks further attempts for
+// the back-off or lockout period. userID is empty for unknown emails.
+func recordLoginFailure(ctx context.Context, c *fiber.Ctx, email, userID, reason string) {
+	recordAuthEvent(ctx, c, userID, "FAILED_LOGIN", fiber.Map{"email": email, "reason": reason})
+
+	if recordAttemptFailure(ctx, emailAttemptKey(email), cfg.LoginLockoutThreshold) {
+		recordAuthEvent(ctx, c, userID, "ACCOUNT_LOCKED", fiber.Map{"email": email, "failures": cfg.LoginLockoutThreshold})
+		log.Printf("Password login for %s locked for %s after %d failed attempts", email, cfg.LoginLockoutDuration, cfg.LoginLockoutThreshold)
+	}
+	if recordAttemptFailure(ctx, ipAttemptKey(c.IP()), cfg.LoginIPThreshold) {
+		recordAuthEvent(ctx, c, "", "IP_LOCKED", fiber.Map{"ip": c.IP(), "failures": cfg.LoginIPThreshold})
+		log.Printf("Password logins from %s locked for %s after %d failed attempts", c.IP(), cfg.LoginLockoutDuration, cfg.LoginIPThreshold)
+	}
+}
+
+// recordServiceClientFailure counts wrong client credentials of the client
+// IP. clientID is the one presented, it may not exist.
+func recordServiceClientFailure(ctx context.Context, c *fiber.Ctx, clientID string) {
+	recordAuthEvent(ctx, c, "", "FAILED_CLIENT_AUTH", fiber.Map{"client_id": clientID})
+
+	if recordAttemptFailure(ctx, serviceClientAttemptKey(c.IP()), cfg.LoginIPThreshold) {
+		recordAuthEvent(ctx, c, "", "IP_LOCKED", fiber.Map{"ip": c.IP(), "failures": cfg.LoginIPThreshold, "scope": "service_client"})
+		log.Printf("Client authentication from %s locked for %s after %d failed attempts", c.IP(), cfg.LoginLockoutDuration, cfg.LoginIPThreshold)
+	}
+}
+
+// recordAttemptFailure increments the counter of key and blocks it. It
+// reports whether this failure reached the lockout threshold.
+func recordAttemptFailure(ctx context.Context, key string, threshold int) bool {
+	now := time.Now().UTC()
+	pgKey := pgtype.Text{String: key, Valid: true}
+	failures, err := queries.RecordLoginFailure(ctx,
+		pgKey,
+		pgtype.Timestamp{Time: now, Valid: true},
+		pgtype.Timestamp{Time: now.Add(-cfg.LoginFailureWindow), Valid: true},
+	)
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+		return false
+	}
+
+	locked := threshold > 0 && int(failures) >= threshold
+	delay := loginBackoff(int(failures))
+	if locked {
+		delay = cfg.LoginLockoutDuration
+	}
+	if err := queries.BlockLoginAttempts(ctx, pgKey, pgtype.Timestamp{Time: now.Add(delay), Valid: true}); err != nil {
+		log.Printf("Failed to record login back-off: %v", err)
+	}
+	return locked && int(failures) == threshold
+}
+
+// loginBackoff is LOGIN_BACKOFF_BASE doubled for every failure after the
+// first, capped at the lockout duration
+func loginBackoff(failures int) time.Duration {
+	delay := cfg.LoginBackoffBase
+	for i := 1; i < failures && delay < cfg.LoginLockoutDuration; i++ {
+		delay *= 2
+	}
+	if delay > cfg.LoginLockoutDuration {
+		delay = cfg.LoginLockoutDuration
+	}
+	return delay
+}
+
+// clearLoginFailures resets the counter of an email after a successful login.
+// The IP counter is left alone so that an attacker cannot reset it by
+// signing in to an account of their own.
+func clearLoginFailures(ctx context.Context, email string) {
+	if err := queries.ClearLoginFailures(ctx, pgtype.Text{String: emailAttemptKey(email), Valid: true}); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
+}
+
+// @Summary Unlock user
+// @Description Lift the brute-force lockout and back-off of a user's password login
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/users/{id}/unlock [post]
+func unlockUserHandler(c *fiber.Ctx) error {
+	ctx := context.Background()
+	user, err := queries.GetUserByID(ctx, pgtype.Text{String: c.Params("id"), Valid: true})
+	if err != nil || user.DeletedAt.Valid {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+	if err := checkCanManageUser(ctx, c, user.ID); err != nil {
+		return err
+	}
+
+	if err := queries.ClearLoginFailures(ctx, pgtype.Text{String: emailAttemptKey(user.Email.String), Valid: true}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to unlock user")
+	}
+	recordAuthEvent(ctx, c, user.ID, "ACCOUNT_UNLOCKED", nil)
+
+	return c.JSON(fiber.Map{"status": "unlocked"})
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/config"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+// loginFailure is a row of login_failures
+type loginFailure struct {
+	failures      int32
+	lastFailureAt time.Time
+	blockedUntil  time.Time
+}
+
+// bruteForceServer serves /login for alice, whose password is "correct
+// horse", and keeps the login_failures table
+type bruteForceServer struct {
+	*testServer
+	attempts map[string]*loginFailure
+}
+
+func newBruteForceServer(t *testing.T) *bruteForceServer {
+	s := &bruteForceServer{testServer: newTestServer(t), attempts: map[string]*loginFailure{}}
+	cfg.LoginFailureWindow = time.Hour
+	cfg.LoginBackoffBase = time.Minute
+	cfg.LoginLockoutThreshold = 4
+	cfg.LoginIPThreshold = 50
+	cfg.LoginLockoutDuration = 15 * time.Minute
+	s.app.Post("/login", loginHandler)
+
+	s.addUser("alice", "alice@example.com")
+	hash, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.db.Handle("GetUserByEmail", func(args ...any) (any, error) {
+		if args[0].(pgtype.Text).String != "alice@example.com" {
+			return nil, nil
+		}
+		return db.GetUserByEmailRow{ID: "alice", Email: args[0].(pgtype.Text), Password: pgtype.Text{String: hash, Valid: true},
+			Status: statusActive}, nil
+	})
+
+	// Same statements as the queries
+	s.db.Handle("RecordLoginFailure", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		key, now, windowStart := args[0].(pgtype.Text).String, args[1].(pgtype.Timestamp).Time, args[2].(pgtype.Timestamp).Time
+		attempt, ok := s.attempts[key]
+		switch {
+		case !ok:
+			attempt = &loginFailure{failures: 1}
+			s.attempts[key] = attempt
+		case attempt.lastFailureAt.Before(windowStart):
+			attempt.failures = 1
+		default:
+			attempt.failures++
+		}
+		attempt.lastFailureAt = now
+		return attempt.failures, nil
+	})
+	s.db.Handle("BlockLoginAttempts", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if attempt, ok := s.attempts[args[0].(pgtype.Text).String]; ok {
+			attempt.blockedUntil = args[1].(pgtype.Timestamp).Time
+		}
+		return nil, nil
+	})
+	s.db.Handle("GetLoginBlockedUntil", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var blockedUntil pgtype.Timestamp
+		for _, key := range []any{args[0], args[1]} {
+			attempt, ok := s.attempts[key.(pgtype.Text).String]
+			if ok && attempt.blockedUntil.After(args[2].(pgtype.Timestamp).Time) && attempt.blockedUntil.After(blockedUntil.Time) {
+				blockedUntil = pgtype.Timestamp{Time: attempt.blockedUntil, Valid: true}
+			}
+		}
+		return blockedUntil, nil
+	})
+	s.db.Handle("ClearLoginFailures", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.attempts, args[0].(pgtype.Text).String)
+		return nil, nil
+	})
+	return s
+}
+
+// login returns the status and the Retry-After header of a password login
+func (s *bruteForceServer) login(t *testing.T, email, password string) (int, string) {
+	t.Helper()
+	data, err := json.Marshal(LoginRequest{Email: email, Password: password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(fiber.MethodPost, "/login", bytes.NewReader(data))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter)
+}
+
+// elapse moves the stored failures and blocks d into the past
+func (s *bruteForceServer) elapse(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attempt := range s.attempts {
+		attempt.lastFailureAt = attempt.lastFailureAt.Add(-d)
+		attempt.blockedUntil = attempt.blockedUntil.Add(-d)
+	}
+}
+
+// delay is how long key was blocked for by its last failure
+func (s *bruteForceServer) delay(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return 0
+	}
+	return attempt.blockedUntil.Sub(attempt.lastFailureAt)
+}
+
+// TestLoginBackoff fails to sign in until the account locks: every failure
+// doubles the wait, during which even the right password is turned away
+func TestLoginBackoff(t *testing.T) {
+	s := newBruteForceServer(t)
+	key := emailAttemptKey("alice@example.com")
+
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 15 * time.Minute} {
+		if status, _ := s.login(t, "alice@example.com", "wrong"); status != fiber.StatusUnauthorized {
+			t.Fatalf("failure %d: got %d, want %d", i+1, status, fiber.StatusUnauthorized)
+		}
+		if got := s.delay(key); got != want {
+			t.Errorf("failure %d: blocked for %s, want %s", i+1, got, want)
+		}
+		status, retryAfter := s.login(t, "alice@example.com", "correct horse")
+		if status != fiber.StatusTooManyRequests {
+			t.Fatalf("failure %d: login while blocked: got %d, want %d", i+1, status, fiber.StatusTooManyRequests)
+		}
+		if seconds, _ := strconv.Atoi(retryAfter); seconds <= 0 || seconds > int(want.Seconds()) {
+			t.Errorf("failure %d: Retry-After %q, want at most %d seconds", i+1, retryAfter, int(want.Seconds()))
+		}
+		s.elapse(want)
+	}
+	if !s.recorded("ACCOUNT_LOCKED") {
+		t.Error("the lockout was not recorded")
+	}
+
+	if status, _ := s.login(t, "alice@example.com", "correct horse"); status != fiber.StatusOK {
+		t.Fatalf("login after the lockout: got %d, want %d", status, fiber.StatusOK)
+	}
+	if _, ok := s.attempts[key]; ok {
+		t.Error("a successful login did not clear the failures of the email")
+	}
+	if _, ok := s.attempts[ipAttemptKey("0.0.0.0")]; !ok {
+		t.Error("a successful login cleared the failures of the IP")
+	}
+}
+
+func TestLoginBackoffWindow(t *testing.T) {
+	s := newBruteForceServer(t)
+	key := emailAttemptKey("alice@example.com")
+
+	s.login(t, "alice@example.com", "wrong")
+	s.elapse(time.Minute)
+	s.login(t, "alice@example.com", "wrong")
+	if got := s.delay(key); got != 2*time.Minute {
+		t.Fatalf("second failure: blocked for %s, want %s", got, 2*time.Minute)
+	}
+
+	s.elapse(cfg.LoginFailureWindow + time.Minute)
+	s.login(t, "alice@example.com", "wrong")
+	if got := s.delay(key); got != time.Minute {
+		t.Errorf("failure after the window: blocked for %s, want %s", got, time.Minute)
+	}
+}
+
+// TestLoginIPLockout guesses passwords of unknown emails from one IP: they
+// count like registered ones and lock every email out from that IP
+func TestLoginIPLockout(t *testing.T) {
+	s := newBruteForceServer(t)
+	cfg.LoginIPThreshold = 3
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		// The IP backs off like an email
+		s.elapse(s.delay(ipAttemptKey("0.0.0.0")))
+		if status, _ := s.login(t, email, "guess"); status != fiber.StatusUnauthorized {
+			t.Fatalf("%s: got %d, want %d", email, status, fiber.StatusUnauthorized)
+		}
+		if got := s.delay(emailAttemptKey(email)); got != time.Minute {
+			t.Errorf("%s: blocked for %s, want %s", email, got, time.Minute)
+		}
+	}
+	if !s.recorded("IP_LOCKED") {
+		t.Error("the IP lockout was not recorded")
+	}
+	if s.recorded("ACCOUNT_LOCKED") {
+		t.Error("an account was locked")
+	}
+
+	if status, _ := s.login(t, "alice@example.com", "correct horse"); status != fiber.StatusTooManyRequests {
+		t.Errorf("another email from the locked IP: got %d, want %d", status, fiber.StatusTooManyRequests)
+	}
+}
+
+func TestLoginBackoffCap(t *testing.T) {
+	cfg = &config.Config{LoginBackoffBase: time.Second, LoginLockoutDuration: 15 * time.Minute}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{10, 512 * time.Second},
+		{11, 15 * time.Minute},
+		{1000, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := loginBackoff(tt.failures); got != tt.want {
+			t.Errorf("%d failures: got %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+const (
+	// emailLoginPurpose prefixes the verification_tokens identifier of
+	// email login links ("email-login:<user id>")
+	emailLoginPurpose = "email-login"
+	// emailLoginCodePurpose prefixes the identifier of email login codes
+	// ("email-login-code:<user id>"). Codes are short, so the stored hash
+	// covers the user ID as well and a code only works for its account.
+	emailLoginCodePurpose = "email-login-code"
+	emailLoginCodeDigits  = 6
+)
+
+type EmailLoginRequest struct {
+	Email string `json:"email"`
+}
+
+// EmailLoginVerifyRequest completes an email login with either the code
+// (together with the email address) or the token of the emailed link
+type EmailLoginVerifyRequest struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+	Token string `json:"token"`
+}
+
+// generateLoginCode returns a random numeric code of emailLoginCodeDigits
+func generateLoginCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < emailLoginCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", emailLoginCodeDigits, n), nil
+}
+
+// sendEmailLogin mails a sign-in code and link to the user, replacing the
+// ones sent before
+func sendEmailLogin(ctx context.Context, userID, email, name, locale string) error {
+	token, err := createVerificationToken(ctx, queries, emailLoginPurpose, userID, cfg.EmailLoginTTL)
+	if err != nil {
+		return err
+	}
+
+	code, err := generateLoginCode()
+	if err != nil {
+		return err
+	}
+	identifier := pgtype.Text{String: emailLoginCodePurpose + ":" + userID, Valid: true}
+	if err := queries.DeleteVerificationTokens(ctx, identifier); err != nil {
+		return err
+	}
+	err = queries.CreateVerificationToken(ctx,
+		identifier,
+		pgtype.Text{String: utils.HashToken(userID + ":" + code), Valid: true},
+		pgtype.Timestamp{Time: time.Now().UTC().Add(cfg.EmailLoginTTL), Valid: true},
+	)
+	if err != nil {
+		return err
+	}
+
+	// The link opens the frontend, which posts the token: mail scanners that
+	// prefetch links would otherwise use it up
+	return deliverMail(email, "email_login", locale, fiber.Map{
+		"Name":      name,
+		"Code":      code,
+		"Link":      cfg.FrontendURL + "/email-login?token=" + url.QueryEscape(token),
+		"ExpiresIn": cfg.EmailLoginTTL,
+	})
+}
+
+// @Summary Request email login
+// @Description Passwordless login: email a 6-digit code and a sign-in link, both valid once for EMAIL_LOGIN_TTL. Redeem either at /auth/email-login/verify. The response is the same whether or not the account exists.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body EmailLoginRequest true "Email Login Request"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/email-login [post]
+func emailLoginHandler(c *fiber.Ctx) error {
+	var req EmailLoginRequest
+	if err := c.BodyParser(&req); err != nil || !utils.ValidateEmail(req.Email) {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	user, err := queries.GetUserByEmail(ctx, pgtype.Text{String: req.Email, Valid: true})
+	if err == nil {
+		if err := sendEmailLogin(ctx, user.ID, user.Email.String, user.Name.String, c.Get(fiber.HeaderAcceptLanguage)); err != nil {
+			log.Printf("Failed to send login email to %s: %v", user.Email.String, err)
+		}
+		recordAuthEvent(ctx, c, user.ID, "EMAIL_LOGIN_REQUEST", nil)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status": "If the account exists, a sign-in email has been sent",
+	})
+}
+
+// @Summary Complete email login
+// @Description Sign in with the emailed code (with email) or the token of the emailed link. Wrong codes count as failed logins, and a code is discarded after EMAIL_LOGIN_MAX_ATTEMPTS wrong guesses. Accounts with a second factor get an MFA challenge as with password login.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body EmailLoginVerifyRequest true "Email Login Verify Request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/email-login/verify [post]
+func verifyEmailLoginHandler(c *fiber.Ctx) error {
+	var req EmailLoginVerifyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	switch {
+	case req.Token != "":
+		userID, err := consumeVerificationToken(ctx, queries, emailLoginPurpose, req.Token)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired login link")
+		}
+		return finishEmailLogin(ctx, c, userID, "email_link")
+	case req.Email != "" && req.Code != "":
+		return verifyEmailLoginCode(ctx, c, req.Email, strings.TrimSpace(req.Code))
+	}
+	return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+}
+
+// verifyEmailLoginCode checks a code against the one last sent to email
+func verifyEmailLoginCode(ctx context.Context, c *fiber.Ctx, email, code string) error {
+	if err := checkLoginBlocked(ctx, c, email); err != nil {
+		return err
+	}
+	user, err := queries.GetUserByEmail(ctx, pgtype.Text{String: email, Valid: true})
+	if err != nil {
+		recordLoginFailure(ctx, c, email, "", "unknown_email")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired code")
+	}
+
+	userID, err := consumeVerificationToken(ctx, queries, emailLoginCodePurpose, user.ID+":"+code)
+	if err != nil || userID != user.ID {
+		identifier := pgtype.Text{String: emailLoginCodePurpose + ":" + user.ID, Valid: true}
+		attempts, err := queries.AddVerificationAttempt(ctx, identifier)
+		if err == nil && int(attempts) >= cfg.EmailLoginMaxAttempts {
+			if err := queries.DeleteVerificationTokens(ctx, identifier); err != nil {
+				log.Printf("Failed to discard email login code of user %s: %v", user.ID, err)
+			}
+		}
+		recordLoginFailure(ctx, c, email, user.ID, "invalid_email_code")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired code")
+	}
+
+	return finishEmailLogin(ctx, c, user.ID, "email_code")
+}
+
+// finishEmailLogin signs in a user who proved access to their mailbox with
+// method ("email_code" or "email_link")
+func finishEmailLogin(ctx context.Context, c *fiber.Ctx, userID, method string) error {
+	pgUserID := pgtype.Text{String: userID, Valid: true}
+	user, err := queries.GetUserByID(ctx, pgUserID)
+	if err != nil || user.DeletedAt.Valid {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired code")
+	}
+
+	// The code and the link came in the same message, using one uses both
+	for _, purpose := range []string{emailLoginPurpose, emailLoginCodePurpose} {
+		if err := queries.DeleteVerificationTokens(ctx, pgtype.Text{String: purpose + ":" + userID, Valid: true}); err != nil {
+			log.Printf("Failed to discard email login tokens of user %s: %v", userID, err)
+		}
+	}
+
+	if err := checkAccountStatus(user.Status, user.StatusExpiresAt); err != nil {
+		recordAuthEvent(ctx, c, user.ID, "FAILED_LOGIN", fiber.Map{"method": method, "reason": "account_" + user.Status})
+		return err
+	}
+
+	// Receiving the message proves the address
+	if !user.EmailVerified.Valid {
+		verifiedAt := pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}
+		_, err := queries.UpdateUser(ctx,
+			pgUserID,
+			pgtype.Text{Valid: false},
+			pgtype.Text{Valid: false},
+			verifiedAt,
+			pgtype.Text{Valid: false},
+		)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify email")
+		}
+		user.EmailVerified = verifiedAt
+		recordAuthEvent(ctx, c, user.ID, "EMAIL_VERIFIED", fiber.Map{"method": method})
+	}
+
+	return completeLogin(ctx, c, user.ID, user.Email.String, newSessionUser(user), auth.AMREmail, "LOGIN", fiber.Map{"method": method})
+}
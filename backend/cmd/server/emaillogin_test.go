@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/mailer"
+)
+
+// testMailer hands the sent messages to the test
+type testMailer chan mailer.Message
+
+func (m testMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m <- msg
+	return nil
+}
+
+// verificationToken is a row of verification_tokens
+type verificationToken struct {
+	identifier string
+	expires    time.Time
+	attempts   int32
+}
+
+// emailLoginServer serves the email login routes for alice on top of
+// bruteForceServer and keeps verification tokens in memory
+type emailLoginServer struct {
+	*bruteForceServer
+	outbox testMailer
+	// tokens by token hash
+	tokens map[string]*verificationToken
+}
+
+func newEmailLoginServer(t *testing.T) *emailLoginServer {
+	s := &emailLoginServer{bruteForceServer: newBruteForceServer(t), outbox: make(testMailer, 10), tokens: map[string]*verificationToken{}}
+	cfg.EmailLoginTTL = 15 * time.Minute
+	cfg.EmailLoginMaxAttempts = 3
+	cfg.FrontendURL = testOrigin
+	mail = s.outbox
+	var err error
+	if mailTemplates, err = mailer.LoadTemplates("en"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same statements as the queries
+	s.db.Handle("CreateVerificationToken", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.tokens[args[1].(pgtype.Text).String] = &verificationToken{identifier: args[0].(pgtype.Text).String, expires: args[2].(pgtype.Timestamp).Time}
+		return nil, nil
+	})
+	s.db.Handle("ConsumeVerificationToken", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		hash := args[0].(pgtype.Text).String
+		token, ok := s.tokens[hash]
+		if !ok || strings.Split(token.identifier, ":")[0] != args[1].(pgtype.Text).String {
+			return nil, nil
+		}
+		delete(s.tokens, hash)
+		return db.ConsumeVerificationTokenRow{Identifier: token.identifier, Expires: pgtype.Timestamp{Time: token.expires, Valid: true}}, nil
+	})
+	s.db.Handle("GetVerificationToken", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		token, ok := s.tokens[args[0].(pgtype.Text).String]
+		if !ok || strings.Split(token.identifier, ":")[0] != args[1].(pgtype.Text).String {
+			return nil, nil
+		}
+		return db.GetVerificationTokenRow{Identifier: token.identifier, Expires: pgtype.Timestamp{Time: token.expires, Valid: true}}, nil
+	})
+	s.db.Handle("DeleteVerificationTokens", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for hash, token := range s.tokens {
+			if token.identifier == args[0].(pgtype.Text).String {
+				delete(s.tokens, hash)
+			}
+		}
+		return nil, nil
+	})
+	s.db.Handle("AddVerificationAttempt", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, token := range s.tokens {
+			if token.identifier == args[0].(pgtype.Text).String {
+				token.attempts++
+				return token.attempts, nil
+			}
+		}
+		return nil, nil
+	})
+
+	s.app.Post("/email-login", emailLoginHandler)
+	s.app.Post("/email-login/verify", verifyEmailLoginHandler)
+	return s
+}
+
+var loginCodePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// requestLogin asks for a sign-in email to alice and returns its code and
+// the token of its link
+func (s *emailLoginServer) requestLogin(t *testing.T) (string, string) {
+	t.Helper()
+	if status := s.call(t, "/email-login", "", EmailLoginRequest{Email: "alice@example.com"}, nil); status != fiber.StatusAccepted {
+		t.Fatalf("request: got %d, want %d", status, fiber.StatusAccepted)
+	}
+	var msg mailer.Message
+	select {
+	case msg = <-s.outbox:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no sign-in email was sent")
+	}
+
+	code := loginCodePattern.FindString(msg.Subject)
+	link, err := url.Parse(regexp.MustCompile(`https://\S+/email-login\?\S+`).FindString(msg.Text))
+	if err != nil || code == "" {
+		t.Fatalf("no code or link in %q: %v", msg.Text, err)
+	}
+	return code, link.Query().Get("token")
+}
+
+func (s *emailLoginServer) verify(t *testing.T, req EmailLoginVerifyRequest) int {
+	t.Helper()
+	var session map[string]any
+	status := s.call(t, "/email-login/verify", "", req, &session)
+	if status == fiber.StatusOK && session["access_token"] == nil {
+		t.Fatal("login returned no access token")
+	}
+	return status
+}
+
+func TestEmailLoginCode(t *testing.T) {
+	s := newEmailLoginServer(t)
+	code, token := s.requestLogin(t)
+
+	if status := s.verify(t, EmailLoginVerifyRequest{Email: "alice@example.com", Code: code}); status != fiber.StatusOK {
+		t.Fatalf("code: got %d, want %d", status, fiber.StatusOK)
+	}
+	if !s.recorded("LOGIN") {
+		t.Error("the login was not recorded")
+	}
+	if status := s.verify(t, EmailLoginVerifyRequest{Email: "alice@example.com", Code: code}); status != fiber.StatusUnauthorized {
+		t.Errorf("used code: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	// The link came with the code
+	if status := s.verify(t, EmailLoginVerifyRequest{Token: token}); status != fiber.StatusUnauthorized {
+		t.Errorf("link of the used code: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+func TestEmailLoginLink(t *testing.T) {
+	s := newEmailLoginServer(t)
+	code, token := s.requestLogin(t)
+
+	if status := s.verify(t, EmailLoginVerifyRequest{Token: token}); status != fiber.StatusOK {
+		t.Fatalf("link: got %d, want %d", status, fiber.StatusOK)
+	}
+	if status := s.verify(t, EmailLoginVerifyRequest{Token: token}); status != fiber.StatusUnauthorized {
+		t.Errorf("used link: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	if status := s.verify(t, EmailLoginVerifyRequest{Email: "alice@example.com", Code: code}); status != fiber.StatusUnauthorized {
+		t.Errorf("code of the used link: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+// TestEmailLoginNewEmail replaces the code and link of an earlier email
+func TestEmailLoginNewEmail(t *testing.T) {
+	s := newEmailLoginServer(t)
+	_, oldToken := s.requestLogin(t)
+	_, token := s.requestLogin(t)
+
+	if status := s.verify(t, EmailLoginVerifyRequest{Token: oldToken}); status != fiber.StatusUnauthorized {
+		t.Errorf("link of the earlier email: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	if status := s.verify(t, EmailLoginVerifyRequest{Token: token}); status != fiber.StatusOK {
+		t.Errorf("link of the last email: got %d, want %d", status, fiber.StatusOK)
+	}
+}
+
+// TestEmailLoginEmailChanged discards the code and link sent to the address
+// an admin replaced
+func TestEmailLoginEmailChanged(t *testing.T) {
+	s := newEmailLoginServer(t)
+	code, token := s.requestLogin(t)
+	if err := deleteEmailTokens(context.Background(), queries, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := s.verify(t, EmailLoginVerifyRequest{Token: token}); status != fiber.StatusUnauthorized {
+		t.Errorf("link: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	if status := s.verify(t, EmailLoginVerifyRequest{Email: "alice@example.com", Code: code}); status != fiber.StatusUnauthorized {
+		t.Errorf("code: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+// TestEmailLoginWrongCodes guesses codes: every guess counts as a failed
+// login and the code is discarded after EMAIL_LOGIN_MAX_ATTEMPTS of them
+func TestEmailLoginWrongCodes(t *testing.T) {
+	s := newEmailLoginServer(t)
+	code, _ := s.requestLogin(t)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := range cfg.EmailLoginMaxAttempts {
+		s.elapse(s.delay(emailAttemptKey("alice@example.com")))
+		if status := s.verify(t, EmailLoginVerifyRequest{Email: "alice@example.com", Code: wrong}); status != fiber.StatusUnauthorized {
+			t.Fatalf("guess %d: got %d, want %d", i+1, status, fiber.StatusUnauthorized)
+		}
+	}
+	if failures := s.attempts[emailAttemptKey("alice@example.com")].failures; int(failures) != cfg.EmailLoginMaxAttempts {
+		t.Errorf("counted %d failed logins, want %d", failures, cfg.EmailLoginMaxAttempts)
+	}
+
+	s.elapse(s.delay(emailAttemptKey("alice@example.com")))
+	if status := s.verify(t, EmailLoginVerifyRequest{Email: "alice@example.com", Code: code}); status != fiber.StatusUnauthorized {
+		t.Errorf("right code after too many guesses: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+func TestEmailLoginUnknownEmail(t *testing.T) {
+	s := newEmailLoginServer(t)
+	if status := s.call(t, "/email-login", "", EmailLoginRequest{Email: "mallory@example.com"}, nil); status != fiber.StatusAccepted {
+		t.Fatalf("got %d, want %d", status, fiber.StatusAccepted)
+	}
+	if len(s.tokens) != 0 {
+		t.Error("a sign-in code was issued for an unknown email")
+	}
+	if status := s.verify(t, EmailLoginVerifyRequest{Email: "mallory@example.com", Code: "123456"}); status != fiber.StatusUnauthorized {
+		t.Errorf("code of an unknown email: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
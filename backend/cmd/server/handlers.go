@@ -1,20 +1,15 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
+	"log"
 	//"net/url"
-	"os"
 	//"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/yourusername/skoservice-authenserver/internal/auth"
-	"github.com/yourusername/skoservice-authenserver/internal/db"
 	"github.com/yourusername/skoservice-authenserver/internal/utils"
 )
 
@@ -100,18 +95,25 @@ func registerHandler(c *fiber.Ctx) error {
 	if err != nil {
 		return fiber.NewError(fiber.StatusConflict, "User likely already exists: "+err.Error())
 	}
+	recordAuthEvent(context.Background(), c, user.ID, "REGISTER", fiber.Map{"method": "password"})
+
+	if err := sendVerificationEmail(context.Background(), user.ID, user.Email.String, user.Name.String, c.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Email.String, err)
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(user)
 }
 
 // @Summary Login user
-// @Description Login with email and password to get JWT token
+// @Description Login with email and password to get an access token and a refresh token
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param request body LoginRequest true "Login Request"
 // @Success 200 {object} map[string]interface{}
 // @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /auth/login [post]
 func loginHandler(c *fiber.Ctx) error {
@@ -120,26 +122,34 @@ func loginHandler(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
+	ctx := context.Background()
+	if err := checkLoginBlocked(ctx, c, req.Email); err != nil {
+		return err
+	}
+
 	pgEmail := pgtype.Text{String: req.Email, Valid: true}
-	user, err := queries.GetUserByEmail(context.Background(), pgEmail)
+	user, err := queries.GetUserByEmail(ctx, pgEmail)
 	if err != nil {
+		recordLoginFailure(ctx, c, req.Email, "", "unknown_email")
 		return fiber.NewError(fiber.StatusUnauthorized, "Invalid credentials")
 	}
 
 	if !user.Password.Valid || !utils.CheckPasswordHash(req.Password, user.Password.String) {
+		recordLoginFailure(ctx, c, req.Email, user.ID, "invalid_password")
 		return fiber.NewError(fiber.StatusUnauthorized, "Invalid credentials")
 	}
 
-	token, payload, err := tokenMaker.CreateToken(user.ID, user.Email.String, []string{}, 24*time.Hour)
-	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create access token")
+	if err := checkAccountStatus(user.Status, user.StatusExpiresAt); err != nil {
+		recordAuthEvent(ctx, c, user.ID, "FAILED_LOGIN", fiber.Map{"email": req.Email, "reason": "account_" + user.Status})
+		return err
 	}
-	_ = payload
 
-	return c.JSON(fiber.Map{
-		"access_token": token,
-		"user":         user,
-	})
+	if cfg.RequireVerifiedEmail && !user.EmailVerified.Valid {
+		recordAuthEvent(ctx, c, user.ID, "FAILED_LOGIN", fiber.Map{"email": req.Email, "reason": "email_not_verified"})
+		return fiber.NewError(fiber.StatusForbidden, "Email address is not verified")
+	}
+
+	return completeLogin(ctx, c, user.ID, req.Email, newSessionUserByEmail(user), auth.AMRPassword, "LOGIN", fiber.Map{"method": "password"})
 }
 
 // @Summary Get User Profile
@@ -154,7 +164,7 @@ func getUserMeHandler(c *fiber.Ctx) error {
 	payload := c.Locals("payload").(*auth.Payload)
 	pgID := pgtype.Text{String: payload.UserID, Valid: true}
 	user, err := queries.GetUserByID(context.Background(), pgID)
-	if err != nil {
+	if err != nil || user.DeletedAt.Valid {
 		return fiber.NewError(fiber.StatusNotFound, "User not found")
 	}
 	return c.JSON(user)
@@ -188,145 +198,9 @@ func updateUserMeHandler(c *fiber.Ctx) error {
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update user")
 	}
-	return c.JSON(user)
-}
-
-// @Summary Get Google OAuth URL
-// @Description Get the URL to redirect the user for Google OAuth
-// @Tags Auth
-// @Produce json
-// @Success 200 {object} map[string]string
-// @Router /auth/oauth/google/url [get]
-func googleUrlHandler(c *fiber.Ctx) error {
-	clientID := os.Getenv("OAUTH_GOOGLE_CLIENT_ID")
-	redirectURI := os.Getenv("OAUTH_GOOGLE_REDIRECT_URL")
-	if redirectURI == "" {
-		redirectURI = "http://localhost:3000/auth/callback/google"
-	}
-	// Scope: email profile
-	scope := "https://www.googleapis.com/auth/userinfo.email https://www.googleapis.com/auth/userinfo.profile"
-	authURL := fmt.Sprintf("https://accounts.google.com/o/oauth2/v2/auth?client_id=%s&redirect_uri=%s&response_type=code&scope=%s&access_type=offline&prompt=consent", clientID, redirectURI, scope)
-	return c.JSON(fiber.Map{"url": authURL})
-}
-
-// @Summary Google OAuth Callback
-// @Description Exchange auth code for token
-// @Tags Auth
-// @Accept json
-// @Produce json
-// @Param request body map[string]string true "Code"
-// @Success 200 {object} map[string]interface{}
-// @Router /auth/oauth/google/callback [post]
-func googleCallbackHandler(c *fiber.Ctx) error {
-	type CallbackReq struct {
-		Code string `json:"code"`
-	}
-	var req CallbackReq
-	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
-	}
-
-	clientID := os.Getenv("OAUTH_GOOGLE_CLIENT_ID")
-	clientSecret := os.Getenv("OAUTH_GOOGLE_CLIENT_SECRET")
-	redirectURI := os.Getenv("OAUTH_GOOGLE_REDIRECT_URL")
-	if redirectURI == "" {
-		redirectURI = "http://localhost:3000/auth/callback/google"
-	}
-
-	// Exchange Code for Token
-	reqBodyData := map[string]string{
-		"client_id":     clientID,
-		"client_secret": clientSecret,
-		"code":          req.Code,
-		"grant_type":    "authorization_code",
-		"redirect_uri":  redirectURI,
-	}
-	jsonBody, _ := json.Marshal(reqBodyData)
-
-	tokenReq, _ := http.NewRequest("POST", "https://oauth2.googleapis.com/token", bytes.NewBuffer(jsonBody))
-	tokenReq.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(tokenReq)
-	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to exchange code: "+err.Error())
-	}
-	defer resp.Body.Close()
-
-	type GoogleTokenResp struct {
-		AccessToken string `json:"access_token"`
-		IdToken     string `json:"id_token"`
-		Error       string `json:"error"`
-	}
-	var tokenData GoogleTokenResp
-	if err := json.NewDecoder(resp.Body).Decode(&tokenData); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to parse token response")
-	}
-
-	if tokenData.Error != "" {
-		return fiber.NewError(fiber.StatusUnauthorized, "Google Error: "+tokenData.Error)
-	}
-
-	// Get User Info
-	userReq, _ := http.NewRequest("GET", "https://www.googleapis.com/oauth2/v2/userinfo", nil)
-	userReq.Header.Set("Authorization", "Bearer "+tokenData.AccessToken)
-
-	userResp, err := client.Do(userReq)
-	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch user profile: "+err.Error())
-	}
-	defer userResp.Body.Close()
-
-	type GoogleUser struct {
-		ID            string `json:"id"`
-		Email         string `json:"email"`
-		VerifiedEmail bool   `json:"verified_email"`
-		Name          string `json:"name"`
-		Picture       string `json:"picture"`
-	}
-	var gUser GoogleUser
-	if err := json.NewDecoder(userResp.Body).Decode(&gUser); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to parse user profile")
-	}
-
-	// DB Logic
-	pgEmail := pgtype.Text{String: gUser.Email, Valid: true}
-	user, err := queries.GetUserByEmail(context.Background(), pgEmail)
-
-	if err != nil {
-		// Register
-		id, _ := utils.GenerateID()
-		userID := pgtype.Text{String: id, Valid: true}
-		userName := pgtype.Text{String: gUser.Name, Valid: true}
-		userEmail := pgtype.Text{String: gUser.Email, Valid: true}
-		emailVerified := pgtype.Timestamp{Time: time.Now(), Valid: true} // Trust Google
-		userImage := pgtype.Text{String: gUser.Picture, Valid: gUser.Picture != ""}
-		userPass := pgtype.Text{Valid: false}
-
-		newUser, err := queries.CreateUser(context.Background(), userID, userName, userEmail, emailVerified, userImage, userPass)
-		if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, "Failed to create oauth user: "+err.Error())
-		}
-		user = db.GetUserByEmailRow{
-			ID:            newUser.ID,
-			Name:          newUser.Name,
-			Email:         newUser.Email,
-			EmailVerified: newUser.EmailVerified,
-			Image:         newUser.Image,
-			Password:      newUser.Password,
-			CreatedAt:     newUser.CreatedAt,
-			UpdatedAt:     newUser.UpdatedAt,
-		}
-	}
-
-	// Create Token
-	token, _, err := tokenMaker.CreateToken(user.ID, user.Email.String, []string{}, 24*time.Hour)
-	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create app token")
-	}
-
-	return c.JSON(fiber.Map{
-		"access_token": token,
-		"user":         user,
+	recordAuthEvent(context.Background(), c, user.ID, "PROFILE_UPDATE", fiber.Map{
+		"name_changed":  pgName.Valid,
+		"image_changed": pgImage.Valid,
 	})
+	return c.JSON(user)
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+type IntrospectRequest struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+}
+
+// IntrospectResponse follows RFC 7662. Only Active is set for tokens that
+// are invalid, expired or revoked.
+type IntrospectResponse struct {
+	Active      bool     `json:"active"`
+	Subject     string   `json:"sub,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	ExpiresAt   int64    `json:"exp,omitempty"`
+	IssuedAt    int64    `json:"iat,omitempty"`
+	AuthTime    int64    `json:"auth_time,omitempty"`
+	AMR         []string `json:"amr,omitempty"`
+	TokenID     string   `json:"jti,omitempty"`
+	TokenType   string   `json:"token_type,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+}
+
+type CreateServiceClientReq struct {
+	Name string `json:"name"`
+}
+
+// serviceClientMiddleware authenticates a resource server with HTTP Basic
+// client credentials (client_id:client_secret). Wrong credentials count
+// towards the brute-force lockout of the client IP.
+func serviceClientMiddleware(c *fiber.Ctx) error {
+	authHeader := c.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Basic ") {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="introspection"`)
+		return fiber.NewError(fiber.StatusUnauthorized, "Missing client credentials")
+	}
+	ctx := context.Background()
+	if err := checkServiceClientBlocked(ctx, c); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(authHeader[len("Basic "):])
+	if err != nil {
+		recordServiceClientFailure(ctx, c, "")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid client credentials")
+	}
+	clientID, clientSecret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		recordServiceClientFailure(ctx, c, "")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid client credentials")
+	}
+
+	client, err := queries.GetServiceClient(ctx, pgtype.Text{String: clientID, Valid: true})
+	if err != nil {
+		recordServiceClientFailure(ctx, c, clientID)
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid client credentials")
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+		recordServiceClientFailure(ctx, c, clientID)
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid client credentials")
+	}
+
+	c.Locals("service_client", client.ID)
+	return c.Next()
+}
+
+// @Summary Introspect token
+// @Description RFC 7662 token introspection for resource servers, authenticated with service client credentials (HTTP Basic)
+// @Tags Auth
+// @Accept json,x-www-form-urlencoded
+// @Produce json
+// @Param request body IntrospectRequest true "Introspect Request"
+// @Success 200 {object} IntrospectResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/introspect [post]
+func introspectHandler(c *fiber.Ctx) error {
+	var req IntrospectRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	inactive := IntrospectResponse{Active: false}
+	payload, err := tokenMaker.VerifyToken(req.Token)
+	if err != nil {
+		return c.JSON(inactive)
+	}
+
+	ctx := context.Background()
+	revoked, err := isTokenRevoked(ctx, payload)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to validate token")
+	}
+	if revoked {
+		return c.JSON(inactive)
+	}
+
+	// Roles and permissions are read from the database rather than the
+	// token so that resource servers see the current assignment
+	roles, permissions, err := loadUserAccess(ctx, queries, payload.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load roles")
+	}
+
+	var authTime int64
+	if !payload.AuthTime.IsZero() {
+		authTime = payload.AuthTime.Unix()
+	}
+	return c.JSON(IntrospectResponse{
+		Active:      true,
+		Subject:     payload.UserID,
+		Email:       payload.Email,
+		Roles:       roles,
+		Permissions: permissions,
+		ExpiresAt:   payload.ExpiredAt.Unix(),
+		IssuedAt:    payload.IssuedAt.Unix(),
+		AuthTime:    authTime,
+		AMR:         payload.AMR,
+		TokenID:     payload.ID,
+		TokenType:   "access_token",
+		ClientID:    c.Locals("service_client").(string),
+	})
+}
+
+// @Summary List service clients
+// @Description List the resource servers allowed to introspect tokens
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} db.ListServiceClientsRow
+// @Router /admin/service-clients [get]
+func listServiceClientsHandler(c *fiber.Ctx) error {
+	clients, err := queries.ListServiceClients(context.Background())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to list service clients")
+	}
+	return c.JSON(clients)
+}
+
+// @Summary Create service client
+// @Description Register a resource server. The client secret is only returned once.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateServiceClientReq true "Service Client"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /admin/service-clients [post]
+func createServiceClientHandler(c *fiber.Ctx) error {
+	var req CreateServiceClientReq
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid body")
+	}
+
+	clientID, err := utils.GenerateRandomString(24)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate client ID")
+	}
+	clientSecret, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate client secret")
+	}
+
+	client, err := queries.CreateServiceClient(context.Background(),
+		pgtype.Text{String: clientID, Valid: true},
+		pgtype.Text{String: utils.SanitizeString(req.Name), Valid: true},
+		pgtype.Text{String: utils.HashToken(clientSecret), Valid: true},
+	)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create service client: "+err.Error())
+	}
+	recordAuthEvent(context.Background(), c, "", "CLIENT_CREATED", fiber.Map{"client_id": client.ID, "name": client.Name})
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"client_id":     client.ID,
+		"client_secret": clientSecret,
+		"name":          client.Name,
+		"created_at":    client.CreatedAt,
+	})
+}
+
+// @Summary Delete service client
+// @Description Revoke a resource server's credentials
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Client ID"
+// @Success 200 {object} map[string]string
+// @Router /admin/service-clients/{id} [delete]
+func deleteServiceClientHandler(c *fiber.Ctx) error {
+	err := queries.DeleteServiceClient(context.Background(), pgtype.Text{String: c.Params("id"), Valid: true})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to delete service client")
+	}
+	recordAuthEvent(context.Background(), c, "", "CLIENT_DELETED", fiber.Map{"client_id": c.Params("id")})
+	return c.JSON(fiber.Map{"status": "deleted"})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// startBackgroundJobs launches the periodic maintenance tasks. They stop
+// when ctx is cancelled.
+func startBackgroundJobs(ctx context.Context) {
+	go runPeriodically(ctx, "purge expired sessions", time.Hour, func(ctx context.Context) error {
+		return queries.DeleteExpiredSessions(ctx)
+	})
+	go runPeriodically(ctx, "purge expired token revocations", time.Hour, func(ctx context.Context) error {
+		return queries.DeleteExpiredRevokedTokens(ctx)
+	})
+	go runPeriodically(ctx, "purge expired verification tokens", time.Hour, func(ctx context.Context) error {
+		return queries.DeleteExpiredVerificationTokens(ctx)
+	})
+	go runPeriodically(ctx, "purge expired webauthn sessions", time.Hour, func(ctx context.Context) error {
+		return queries.DeleteExpiredWebAuthnSessions(ctx, pgtype.Timestamp{Time: time.Now().UTC(), Valid: true})
+	})
+	go runPeriodically(ctx, "purge deleted users", time.Hour, func(ctx context.Context) error {
+		cutoff := time.Now().UTC().Add(-cfg.UserDeletionRetention)
+		return queries.PurgeDeletedUsers(ctx, pgtype.Timestamp{Time: cutoff, Valid: true})
+	})
+	go runPeriodically(ctx, "purge stale login failures", time.Hour, func(ctx context.Context) error {
+		now := time.Now().UTC()
+		return queries.DeleteStaleLoginFailures(ctx,
+			pgtype.Timestamp{Time: now.Add(-cfg.LoginFailureWindow), Valid: true},
+			pgtype.Timestamp{Time: now, Valid: true},
+		)
+	})
+	go runPeriodically(ctx, "sign audit log checkpoint", cfg.AuditCheckpointInterval, func(ctx context.Context) error {
+		_, err := auditChain.Checkpoint(ctx)
+		return err
+	})
+	go runPeriodically(ctx, "reload token keys", keyReloadInterval, reloadKeyring)
+	go runPeriodically(ctx, "purge retired token keys", time.Hour, func(ctx context.Context) error {
+		return queries.DeleteRetiredTokenKeys(ctx)
+	})
+}
+
+// runPeriodically calls fn every interval until ctx is cancelled, logging
+// failures instead of stopping.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil {
+				log.Printf("Background job %q failed: %v", name, err)
+			}
+		}
+	}
+}
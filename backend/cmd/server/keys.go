@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
+)
+
+const (
+	// keyReloadInterval is how often every instance re-reads token keys
+	keyReloadInterval = time.Minute
+	// keyPropagationDelay postpones activation of a rotated key until all
+	// instances have loaded it and can verify tokens signed with it
+	keyPropagationDelay = 2 * keyReloadInterval
+)
+
+type TokenKeyResponse struct {
+	ID          string     `json:"kid"`
+	Purpose     string     `json:"purpose"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiresAt   *time.Time `json:"retires_at,omitempty"`
+	Signing     bool       `json:"signing"`
+}
+
+// PublicKeyResponse describes a v2.public verification key in the style of
+// a JWK so that downstream services can validate tokens offline
+type PublicKeyResponse struct {
+	ID          string     `json:"kid"`
+	KeyType     string     `json:"kty"`
+	Curve       string     `json:"crv"`
+	X           string     `json:"x"`
+	Algorithm   string     `json:"alg"`
+	Use         string     `json:"use"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiresAt   *time.Time `json:"retires_at,omitempty"`
+}
+
+// Labels of the keys derived from PASETO_SECRET_KEY, one per use
+const (
+	// keyLabelTokenLocal is the v2.local key until the first rotation
+	keyLabelTokenLocal = "token-local"
+	// keyLabelTokenPublic seeds the v2.public key until the first rotation
+	keyLabelTokenPublic = "token-public"
+	// keyLabelTokenSeal seals the rotated token keys stored in the database
+	keyLabelTokenSeal = "token-seal"
+	// keyLabelOAuthState derives the OIDC nonce and PKCE verifier of logins
+	keyLabelOAuthState = "oauth-state"
+	// keyLabelTOTPSeal seals TOTP secrets
+	keyLabelTOTPSeal = "totp-seal"
+	// keyLabelAuditCheckpoint seeds the audit checkpoint signing key unless
+	// AUDIT_SIGNING_KEY is set. cmd/audit-verify derives it the same way.
+	keyLabelAuditCheckpoint = "audit-checkpoint"
+)
+
+// masterKey is PASETO_SECRET_KEY. It is only the input of key derivation,
+// see secretKey.
+func masterKey() []byte {
+	return []byte(cfg.PasetoSecretKey[:auth.KeySize])
+}
+
+// secretKey is the key of one use of PASETO_SECRET_KEY (a keyLabel*)
+func secretKey(label string) []byte {
+	return auth.DeriveSubkey(masterKey(), label)
+}
+
+// bootstrapKey is the key a purpose uses before it was ever rotated
+func bootstrapKey(purpose auth.Purpose) auth.Key {
+	if purpose == auth.PurposePublic {
+		return auth.NewKey(auth.PurposePublic, secretKey(keyLabelTokenPublic), time.Time{})
+	}
+	return auth.NewKey(auth.PurposeLocal, secretKey(keyLabelTokenLocal), time.Time{})
+}
+
+// loadTokenKeys reads the rotated keys from the database. A purpose that
+// was never rotated has no rows and uses its bootstrap key.
+func loadTokenKeys(ctx context.Context) ([]auth.Key, error) {
+	rows, err := queries.ListTokenKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]auth.Key, 0, len(rows)+2)
+	rotated := map[auth.Purpose]bool{}
+	for _, row := range rows {
+		purpose, err := auth.ParsePurpose(row.Purpose)
+		if err != nil {
+			return nil, err
+		}
+		secret, err := auth.OpenKey(secretKey(keyLabelTokenSeal), row.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open token key %s (was PASETO_SECRET_KEY changed?): %w", row.ID, err)
+		}
+		key := auth.NewKey(purpose, secret, row.ActivatesAt.Time)
+		if row.RetiresAt.Valid {
+			key.RetiresAt = row.RetiresAt.Time
+		}
+		keys = append(keys, key)
+		rotated[purpose] = true
+	}
+
+	for _, purpose := range []auth.Purpose{auth.PurposeLocal, auth.PurposePublic} {
+		if !rotated[purpose] {
+			keys = append(keys, bootstrapKey(purpose))
+		}
+	}
+	return keys, nil
+}
+
+// reloadKeyring refreshes the in-memory keyring so rotations made by any
+// instance are picked up
+func reloadKeyring(ctx context.Context) error {
+	keys, err := loadTokenKeys(ctx)
+	if err != nil {
+		return err
+	}
+	return tokenMaker.Keyring().Replace(keys)
+}
+
+func retiresAtPtr(key auth.Key) *time.Time {
+	if key.RetiresAt.IsZero() {
+		return nil
+	}
+	retiresAt := key.RetiresAt
+	return &retiresAt
+}
+
+// @Summary List token keys
+// @Description List the PASETO keys currently able to verify tokens, without key material
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} TokenKeyResponse
+// @Router /admin/keys [get]
+func listTokenKeysHandler(c *fiber.Ctx) error {
+	now := time.Now().UTC()
+	keys := tokenMaker.Keyring().Keys()
+	resp := make([]TokenKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		signing, _ := tokenMaker.Keyring().SigningKey(key.Purpose, now)
+		resp = append(resp, TokenKeyResponse{
+			ID:          key.ID,
+			Purpose:     string(key.Purpose),
+			ActivatesAt: key.ActivatesAt,
+			RetiresAt:   retiresAtPtr(key),
+			Signing:     key.Purpose == tokenMaker.Purpose() && key.ID == signing.ID,
+		})
+	}
+	return c.JSON(resp)
+}
+
+// @Summary Rotate token key
+// @Description Generate a new PASETO key for the configured token mode (or the given purpose). It starts signing once every instance has loaded it; the previous keys keep verifying until the longest-lived token signed with them has expired.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param purpose query string false "local or public, defaults to PASETO_MODE"
+// @Success 201 {object} TokenKeyResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/keys/rotate [post]
+func rotateTokenKeyHandler(c *fiber.Ctx) error {
+	purpose, err := auth.ParsePurpose(c.Query("purpose", cfg.PasetoMode))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	ctx := context.Background()
+	activatesAt := time.Now().UTC().Add(keyPropagationDelay)
+	next, err := auth.GenerateKey(purpose, activatesAt)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate key")
+	}
+	sealed, err := auth.SealKey(secretKey(keyLabelTokenSeal), next.Secret)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to seal key")
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Tx Error")
+	}
+	defer tx.Rollback(ctx)
+	qtx := queries.WithTx(tx)
+
+	existing, err := qtx.ListTokenKeys(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load keys")
+	}
+	rotatedBefore := false
+	for _, row := range existing {
+		if row.Purpose == string(purpose) {
+			rotatedBefore = true
+		}
+	}
+	pgPurpose := pgtype.Text{String: string(purpose), Valid: true}
+	if !rotatedBefore {
+		// First rotation: record the bootstrap key so that its retirement
+		// is tracked like any other key
+		bootstrap := bootstrapKey(purpose)
+		sealedBootstrap, err := auth.SealKey(secretKey(keyLabelTokenSeal), bootstrap.Secret)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to seal key")
+		}
+		err = qtx.CreateTokenKey(ctx,
+			pgtype.Text{String: bootstrap.ID, Valid: true},
+			pgPurpose,
+			pgtype.Text{String: sealedBootstrap, Valid: true},
+			pgtype.Timestamp{Time: time.Unix(0, 0).UTC(), Valid: true},
+		)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to store key")
+		}
+	}
+
+	retiresAt := activatesAt.Add(cfg.AccessTokenDuration)
+	if err := qtx.RetireTokenKeys(ctx, pgPurpose, pgtype.Timestamp{Time: retiresAt, Valid: true}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retire keys")
+	}
+	err = qtx.CreateTokenKey(ctx,
+		pgtype.Text{String: next.ID, Valid: true},
+		pgPurpose,
+		pgtype.Text{String: sealed, Valid: true},
+		pgtype.Timestamp{Time: activatesAt, Valid: true},
+	)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to store key")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to store key")
+	}
+
+	if err := reloadKeyring(ctx); err != nil {
+		log.Printf("Failed to reload keyring after rotation: %v", err)
+	}
+	log.Printf("Token key rotated, %s key %s activates at %s", purpose, next.ID, activatesAt.Format(time.RFC3339))
+	recordAuthEvent(ctx, c, "", "KEY_ROTATED", fiber.Map{"kid": next.ID, "purpose": string(purpose), "activates_at": activatesAt})
+
+	return c.Status(fiber.StatusCreated).JSON(TokenKeyResponse{ID: next.ID, Purpose: string(purpose), ActivatesAt: activatesAt})
+}
+
+// @Summary Public token keys
+// @Description Ed25519 keys that verify v2.public tokens. Keys are listed before they activate, so verifiers that cache this document for a few minutes never see an unknown kid.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /.well-known/paseto-keys [get]
+func publicKeysHandler(c *fiber.Ctx) error {
+	keys := tokenMaker.Keyring().Keys()
+	resp := make([]PublicKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		if key.Purpose != auth.PurposePublic {
+			continue
+		}
+		resp = append(resp, PublicKeyResponse{
+			ID:          key.ID,
+			KeyType:     "OKP",
+			Curve:       "Ed25519",
+			X:           base64.RawURLEncoding.EncodeToString(key.PublicKey()),
+			Algorithm:   "v2.public",
+			Use:         "sig",
+			ActivatesAt: key.ActivatesAt,
+			RetiresAt:   retiresAtPtr(key),
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", int(keyReloadInterval.Seconds())))
+	return c.JSON(fiber.Map{"keys": resp})
+}
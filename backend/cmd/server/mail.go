@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/yourusername/skoservice-authenserver/internal/mailer"
+)
+
+// mailSendTimeout bounds the delivery of a single message
+const mailSendTimeout = 30 * time.Second
+
+var (
+	mail          mailer.Mailer
+	mailTemplates *mailer.Templates
+)
+
+// setupMailer creates the configured mailer and loads the message templates
+func setupMailer() error {
+	var err error
+	mail, err = mailer.New(mailer.Config{
+		Driver:       cfg.MailDriver,
+		From:         cfg.MailFrom,
+		SMTPHost:     cfg.SMTPHost,
+		SMTPPort:     cfg.SMTPPort,
+		SMTPUsername: cfg.SMTPUsername,
+		SMTPPassword: cfg.SMTPPassword,
+		OutboxDir:    cfg.MailOutboxDir,
+	})
+	if err != nil {
+		return err
+	}
+	mailTemplates, err = mailer.LoadTemplates(cfg.MailDefaultLocale)
+	return err
+}
+
+// deliverMail renders a template and sends it in the background, so that
+// responses do not wait on the mail server and do not reveal by their
+// timing whether a message was sent. locale is usually the request's
+// Accept-Language header.
+func deliverMail(to, template, locale string, data interface{}) error {
+	msg, err := mailTemplates.Render(template, locale, data, to)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+		if err := mail.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %s mail to %s: %v", template, to, err)
+		}
+	}()
+	return nil
+}
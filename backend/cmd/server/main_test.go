@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/audit"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
+	"github.com/yourusername/skoservice-authenserver/internal/config"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/db/dbtest"
+)
+
+const testOrigin = "https://app.example.com"
+
+// testServer points the globals of the server at an in-memory database
+// holding users, refresh sessions, revocations and the audit log. Tests
+// register the other queries they need on db.
+type testServer struct {
+	db  *dbtest.DB
+	app *fiber.App
+
+	mu    sync.Mutex
+	users map[string]db.GetUserByIDRow
+	// sessions by token hash
+	sessions map[string]*db.Session
+	// revoked holds the IDs of revoked access tokens
+	revoked map[string]bool
+	// validAfter is the tokens_valid_after of users
+	validAfter map[string]time.Time
+	// events are the actions written to the audit log
+	events []string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := &testServer{
+		db:         dbtest.New(),
+		app:        fiber.New(),
+		users:      map[string]db.GetUserByIDRow{},
+		sessions:   map[string]*db.Session{},
+		revoked:    map[string]bool{},
+		validAfter: map[string]time.Time{},
+	}
+
+	cfg = &config.Config{
+		PasetoSecretKey:      strings.Repeat("s", auth.KeySize),
+		PasetoMode:           "local",
+		AccessTokenDuration:  15 * time.Minute,
+		RefreshTokenDuration: time.Hour,
+		MFAIssuer:            "Test",
+		MFAChallengeTTL:      5 * time.Minute,
+		WebAuthnRPID:         "app.example.com",
+		WebAuthnRPName:       "Test",
+		WebAuthnOrigins:      []string{testOrigin},
+		WebAuthnTimeout:      5 * time.Minute,
+	}
+	dbPool = s.db
+	queries = db.New(s.db)
+	keyring, err := auth.NewKeyring(bootstrapKey(auth.PurposeLocal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenMaker, err = auth.NewTokenMaker(keyring, auth.PurposeLocal); err != nil {
+		t.Fatal(err)
+	}
+	authMiddleware = newAuthMiddleware()
+	auditChain = audit.NewChain(s.db, ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	if err := setupWebAuthn(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.db.Handle("GetUserByID", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if user, ok := s.users[args[0].(pgtype.Text).String]; ok {
+			return user, nil
+		}
+		return nil, nil
+	})
+	// Users have no roles
+	s.db.Handle("GetUserRoles", func(args ...any) (any, error) { return nil, nil })
+	s.db.Handle("GetUserPermissions", func(args ...any) (any, error) { return nil, nil })
+	// Users have no second factor
+	s.db.Handle("GetUserMFA", func(args ...any) (any, error) { return nil, nil })
+	s.db.Handle("CountWebAuthnCredentials", func(args ...any) (any, error) { return int64(0), nil })
+
+	s.db.Handle("CreateRefreshSession", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		session := &db.Session{
+			ID:           args[0].(pgtype.Text).String,
+			SessionToken: args[1].(pgtype.Text).String,
+			UserID:       args[2].(pgtype.Text).String,
+			Expires:      args[3].(pgtype.Timestamp),
+			FamilyID:     args[4].(pgtype.Text),
+		}
+		s.sessions[session.SessionToken] = session
+		return db.CreateRefreshSessionRow{ID: session.ID, SessionToken: session.SessionToken, UserID: session.UserID,
+			Expires: session.Expires, FamilyID: session.FamilyID}, nil
+	})
+	s.db.Handle("GetRefreshSessionForUpdate", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		session, ok := s.sessions[args[0].(pgtype.Text).String]
+		if !ok {
+			return nil, nil
+		}
+		return db.GetRefreshSessionForUpdateRow{ID: session.ID, SessionToken: session.SessionToken, UserID: session.UserID,
+			Expires: session.Expires, FamilyID: session.FamilyID, RotatedAt: session.RotatedAt, RevokedAt: session.RevokedAt}, nil
+	})
+	s.db.Handle("MarkSessionRotated", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, session := range s.sessions {
+			if session.ID == args[0].(pgtype.Text).String {
+				session.RotatedAt = pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}
+			}
+		}
+		return nil, nil
+	})
+	// Same condition as the WHERE clause of the query
+	s.db.Handle("RevokeSessionFamily", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, session := range s.sessions {
+			if session.FamilyID == args[0].(pgtype.Text) && !session.RevokedAt.Valid {
+				session.RevokedAt = pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}
+			}
+		}
+		return nil, nil
+	})
+	s.db.Handle("DeleteSession", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.sessions, args[0].(pgtype.Text).String)
+		return nil, nil
+	})
+	s.db.Handle("DeleteUserSessions", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for hash, session := range s.sessions {
+			if session.UserID == args[0].(pgtype.Text).String {
+				delete(s.sessions, hash)
+			}
+		}
+		return nil, nil
+	})
+
+	s.db.Handle("RevokeToken", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.revoked[args[0].(pgtype.Text).String] = true
+		return nil, nil
+	})
+	s.db.Handle("RevokeUserTokens", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.validAfter[args[0].(pgtype.Text).String] = args[1].(pgtype.Timestamp).Time
+		return nil, nil
+	})
+	// Same condition as the query
+	s.db.Handle("IsTokenRevoked", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		validAfter, ok := s.validAfter[args[1].(pgtype.Text).String]
+		revoked := s.revoked[args[0].(pgtype.Text).String] || ok && validAfter.After(args[2].(pgtype.Timestamp).Time)
+		return pgtype.Bool{Bool: revoked, Valid: true}, nil
+	})
+
+	// The audit chain only collects the actions
+	s.db.Handle("GetAuditChainHeadForUpdate", func(args ...any) (any, error) {
+		return db.GetAuditChainHeadForUpdateRow{EntryHash: audit.GenesisHash}, nil
+	})
+	s.db.Handle("CreateAuthLog", func(args ...any) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		action := args[2].(pgtype.Text).String
+		s.events = append(s.events, action)
+		return db.CreateAuthLogRow{ID: args[0].(pgtype.Text).String, UserID: args[1].(pgtype.Text), Action: action}, nil
+	})
+	s.db.Handle("SealAuthLog", func(args ...any) (any, error) { return nil, nil })
+	s.db.Handle("SetAuditChainHead", func(args ...any) (any, error) { return nil, nil })
+	return s
+}
+
+// addUser creates an active account
+func (s *testServer) addUser(id, email string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}
+	s.users[id] = db.GetUserByIDRow{
+		ID:            id,
+		Name:          pgtype.Text{String: id, Valid: true},
+		Email:         pgtype.Text{String: email, Valid: true},
+		EmailVerified: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Status:        statusActive,
+	}
+}
+
+func (s *testServer) deleteUser(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.users[id]
+	user.DeletedAt = pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}
+	s.users[id] = user
+}
+
+// recorded reports whether an audit event with action was written
+func (s *testServer) recorded(action string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Contains(s.events, action)
+}
+
+// signIn starts a new token family for userID with a password login
+func (s *testServer) signIn(t *testing.T, userID string) *SessionTokens {
+	t.Helper()
+	tokens, err := issueSession(context.Background(), queries, userID, "", newAuthentication(auth.AMRPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// call sends body as JSON, with accessToken as the bearer token when it is
+// not empty, and decodes the JSON response into out when it is not nil
+func (s *testServer) call(t *testing.T, path, accessToken string, body, out any) int {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(fiber.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if accessToken != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+accessToken)
+	}
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+	return resp.StatusCode
+}
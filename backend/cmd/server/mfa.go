@@ -0,0 +1,655 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/middleware"
+	"github.com/yourusername/skoservice-authenserver/internal/totp"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+// mfaChallengePurpose prefixes the verification_tokens identifier of MFA
+// challenges ("mfa-challenge:<user id>:<first factor amr>"). The first login
+// step hands one out instead of a session when the account has a second
+// factor.
+const mfaChallengePurpose = "mfa-challenge"
+
+const (
+	// recoveryCodeCount is the number of recovery codes issued at once
+	recoveryCodeCount = 10
+	// totpSkew is the number of 30 second steps a code may be off by
+	totpSkew = 1
+)
+
+// Second factors as recorded in auth logs
+const (
+	factorTOTP         = "totp"
+	factorRecoveryCode = "recovery_code"
+	factorWebAuthn     = "webauthn"
+)
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFAChallengeResponse is returned by the first login step of accounts with
+// a second factor, in place of the token pair
+type MFAChallengeResponse struct {
+	MFARequired       bool      `json:"mfa_required"`
+	MFAToken          string    `json:"mfa_token"`
+	MFATokenExpiresAt time.Time `json:"mfa_token_expires_at"`
+	Methods           []string  `json:"methods"`
+}
+
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRPayload is the content to render as a QR code for authenticator apps
+	QRPayload string `json:"qr_payload"`
+}
+
+type MFAStatusResponse struct {
+	// Enabled is set when the user has any second factor
+	Enabled                bool       `json:"enabled"`
+	Methods                []string   `json:"methods"`
+	TOTPConfirmedAt        *time.Time `json:"totp_confirmed_at,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// getConfirmedMFA returns the user's second factor, or nil if the user has
+// none or has not confirmed the enrollment yet
+func getConfirmedMFA(ctx context.Context, q *db.Queries, userID string) (*db.UserMfa, error) {
+	mfa, err := q.GetUserMFA(ctx, pgtype.Text{String: userID, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.ConfirmedAt.Valid {
+		return nil, nil
+	}
+	return &mfa, nil
+}
+
+// secondFactors lists the methods the user can complete an MFA challenge
+// with; it is empty when the account has no second factor
+func secondFactors(ctx context.Context, q *db.Queries, userID string) ([]string, error) {
+	var factors []string
+	mfa, err := getConfirmedMFA(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa != nil {
+		factors = append(factors, factorTOTP, factorRecoveryCode)
+	}
+
+	passkeys, err := q.CountWebAuthnCredentials(ctx, pgtype.Text{String: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	if passkeys > 0 {
+		factors = append(factors, factorWebAuthn)
+	}
+	return factors, nil
+}
+
+// factorAMR maps a second factor to its amr claim value
+var factorAMR = map[string]string{
+	factorTOTP:         auth.AMROneTimePassword,
+	factorRecoveryCode: auth.AMROneTimePassword,
+	factorWebAuthn:     auth.AMRHardwareKey,
+}
+
+// requiresMFA reports whether one of the roles or permissions may only be
+// used with a second factor (MFA_REQUIRED_ROLES, MFA_REQUIRED_PERMISSIONS)
+func requiresMFA(roles, permissions []string) bool {
+	return containsAny(roles, cfg.MFARequiredRoles) || containsAny(permissions, cfg.MFARequiredPermissions)
+}
+
+func containsAny(values, wanted []string) bool {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// completeLogin finishes a successful first login step done with method (an
+// auth.AMR* value). Accounts without a second factor get their session
+// right away and action is recorded; otherwise the response is an MFA
+// challenge to redeem at /auth/mfa/verify or with a passkey. email is the
+// address whose failed logins are reset once the login is complete, empty
+// for methods that are not counted; with a challenge pending that happens in
+// finishMFALogin, so that the first factor alone cannot reset the counter
+// of failed second factors.
+func completeLogin(ctx context.Context, c *fiber.Ctx, userID, email string, user SessionUser, method, action string, metadata fiber.Map) error {
+	factors, err := secondFactors(ctx, queries, userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load MFA settings")
+	}
+
+	if len(factors) == 0 {
+		if email != "" {
+			clearLoginFailures(ctx, email)
+		}
+		tokens, err := issueSession(ctx, queries, userID, "", newAuthentication(method))
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to create access token")
+		}
+		recordAuthEvent(ctx, c, userID, action, metadata)
+		return c.JSON(sessionResponse(tokens, user))
+	}
+
+	token, err := createVerificationToken(ctx, queries, mfaChallengePurpose, userID+":"+method, cfg.MFAChallengeTTL)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create MFA challenge")
+	}
+	recordAuthEvent(ctx, c, userID, "MFA_CHALLENGE", metadata)
+
+	return c.JSON(MFAChallengeResponse{
+		MFARequired:       true,
+		MFAToken:          token,
+		MFATokenExpiresAt: time.Now().UTC().Add(cfg.MFAChallengeTTL),
+		Methods:           factors,
+	})
+}
+
+// checkTOTP validates a code against the user's TOTP secret. A code is
+// accepted only once, even within its validity window.
+func checkTOTP(ctx context.Context, q *db.Queries, mfa *db.UserMfa, code string) (bool, error) {
+	secret, err := auth.OpenKey(secretKey(keyLabelTOTPSeal), mfa.TotpSecret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := totp.Validate(string(secret), code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+	used, err := q.UseTOTPStep(ctx, pgtype.Text{String: mfa.UserID, Valid: true}, pgtype.Int8{Int64: step, Valid: true})
+	if err != nil {
+		return false, err
+	}
+	return used == 1, nil
+}
+
+// normalizeRecoveryCode drops the separators and case of a recovery code as
+// typed by the user
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// checkSecondFactor verifies either a TOTP code or a recovery code (which is
+// used up) and returns the factor that matched
+func checkSecondFactor(ctx context.Context, q *db.Queries, mfa *db.UserMfa, code, recoveryCode string) (string, bool, error) {
+	if code != "" {
+		ok, err := checkTOTP(ctx, q, mfa, code)
+		return factorTOTP, ok, err
+	}
+	if recoveryCode != "" {
+		used, err := q.UseRecoveryCode(ctx,
+			pgtype.Text{String: mfa.UserID, Valid: true},
+			pgtype.Text{String: utils.HashToken(normalizeRecoveryCode(recoveryCode)), Valid: true},
+			pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+		)
+		return factorRecoveryCode, used == 1, err
+	}
+	return "", false, nil
+}
+
+// replaceRecoveryCodes issues a new set of recovery codes, invalidating the
+// previous ones. Only their hashes are stored.
+func replaceRecoveryCodes(ctx context.Context, q *db.Queries, userID string) ([]string, error) {
+	pgUserID := pgtype.Text{String: userID, Valid: true}
+	if err := q.DeleteRecoveryCodes(ctx, pgUserID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for len(codes) < recoveryCodeCount {
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		encoded := recoveryCodeEncoding.EncodeToString(raw)[:10]
+		if err := q.CreateRecoveryCode(ctx, pgUserID, pgtype.Text{String: utils.HashToken(encoded), Valid: true}); err != nil {
+			return nil, err
+		}
+		codes = append(codes, encoded[:5]+"-"+encoded[5:])
+	}
+	return codes, nil
+}
+
+// mfaEnrollmentGuard rejects tokens of accounts that hold a role or
+// permission requiring a second factor but have not enrolled one, or whose
+// session was started without it (e.g. before enrolling). It runs after
+// authMiddleware and checks the user claims, which hold every role and
+// permission even when the token's own were truncated.
+func mfaEnrollmentGuard(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	user := c.Locals("user").(*middleware.UserClaims)
+	if payload.MFAEnrollmentRequired {
+		return fiber.NewError(fiber.StatusForbidden, "Two-factor authentication must be enabled for this account")
+	}
+	if requiresMFA(user.Roles, user.Permissions) && !payload.AuthenticatedWith(auth.AMRMultiFactor) {
+		return fiber.NewError(fiber.StatusForbidden, "Sign in again with your second factor to use this")
+	}
+	return c.Next()
+}
+
+// @Summary Complete MFA login
+// @Description Second login step: redeem the mfa_token returned by login with a TOTP code or a recovery code (passkeys use /auth/webauthn/login). Wrong codes count as failed logins.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body MFAVerifyRequest true "MFA Verify Request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/mfa/verify [post]
+func verifyMFAHandler(c *fiber.Ctx) error {
+	var req MFAVerifyRequest
+	if err := c.BodyParser(&req); err != nil || req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	user, err := loadMFAChallenge(ctx, c, req.MFAToken)
+	if err != nil {
+		return err
+	}
+
+	mfa, err := getConfirmedMFA(ctx, queries, user.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load MFA settings")
+	}
+	if mfa == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "TOTP is not enabled for this account")
+	}
+	factor, ok, err := checkSecondFactor(ctx, queries, mfa, req.Code, req.RecoveryCode)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify code")
+	}
+	if !ok {
+		recordLoginFailure(ctx, c, user.Email.String, user.ID, "invalid_"+factor)
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid code")
+	}
+
+	return finishMFALogin(ctx, c, req.MFAToken, user, factor)
+}
+
+// loadMFAChallenge returns the user an MFA challenge was issued to, as long
+// as the challenge is valid and the user may still sign in. The challenge is
+// only redeemed by finishMFALogin once the second factor checked out, so a
+// typo does not send the user back to the password step.
+func loadMFAChallenge(ctx context.Context, c *fiber.Ctx, token string) (*db.GetUserByIDRow, error) {
+	challenge, err := queries.GetVerificationToken(ctx,
+		pgtype.Text{String: utils.HashToken(token), Valid: true},
+		pgtype.Text{String: mfaChallengePurpose, Valid: true},
+	)
+	if err != nil || time.Now().UTC().After(challenge.Expires.Time) {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+	userID, _, _ := strings.Cut(strings.TrimPrefix(challenge.Identifier, mfaChallengePurpose+":"), ":")
+
+	user, err := queries.GetUserByID(ctx, pgtype.Text{String: userID, Valid: true})
+	if err != nil || user.DeletedAt.Valid {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+	if err := checkLoginBlocked(ctx, c, user.Email.String); err != nil {
+		return nil, err
+	}
+	if err := checkAccountStatus(user.Status, user.StatusExpiresAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// finishMFALogin redeems the MFA challenge after the second factor was
+// verified and starts the session
+func finishMFALogin(ctx context.Context, c *fiber.Ctx, token string, user *db.GetUserByIDRow, factor string) error {
+	subject, err := consumeVerificationToken(ctx, queries, mfaChallengePurpose, token)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+	userID, firstFactor, _ := strings.Cut(subject, ":")
+	if userID != user.ID {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+	clearLoginFailures(ctx, user.Email.String)
+
+	// Challenges issued before the first factor was recorded leave it out
+	var methods []string
+	if firstFactor != "" {
+		methods = append(methods, firstFactor)
+	}
+	methods = append(methods, factorAMR[factor], auth.AMRMultiFactor)
+	tokens, err := issueSession(ctx, queries, user.ID, "", newAuthentication(methods...))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create access token")
+	}
+	recordAuthEvent(ctx, c, user.ID, "LOGIN", fiber.Map{"method": "mfa", "factor": factor})
+
+	return c.JSON(sessionResponse(tokens, newSessionUser(*user)))
+}
+
+// @Summary Get MFA status
+// @Description The second factors of the current user (TOTP, recovery codes, passkeys) and how many recovery codes are left
+// @Tags User
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} MFAStatusResponse
+// @Router /users/me/mfa [get]
+func getMFAStatusHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	ctx := context.Background()
+
+	factors, err := secondFactors(ctx, queries, payload.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load MFA settings")
+	}
+	resp := MFAStatusResponse{Enabled: len(factors) > 0, Methods: factors}
+	if resp.Methods == nil {
+		resp.Methods = []string{}
+	}
+
+	mfa, err := getConfirmedMFA(ctx, queries, payload.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load MFA settings")
+	}
+	if mfa != nil {
+		resp.TOTPConfirmedAt = &mfa.ConfirmedAt.Time
+		resp.RecoveryCodesRemaining, err = queries.CountUnusedRecoveryCodes(ctx, pgtype.Text{String: payload.UserID, Valid: true})
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to count recovery codes")
+		}
+	}
+	return c.JSON(resp)
+}
+
+// @Summary Start TOTP enrollment
+// @Description Create a TOTP secret for the current user. Add it to an authenticator app (scan qr_payload as a QR code) and confirm with a code; until then login is unchanged. Starting again replaces a pending secret.
+// @Tags User
+// @Security BearerAuth
+// @Produce json
+// @Success 201 {object} TOTPEnrollmentResponse
+// @Failure 409 {object} map[string]interface{}
+// @Router /users/me/mfa/totp [post]
+func enrollTOTPHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	ctx := context.Background()
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate secret")
+	}
+	sealed, err := auth.SealKey(secretKey(keyLabelTOTPSeal), []byte(secret))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate secret")
+	}
+
+	// Confirmed factors are left alone, they have to be disabled first
+	_, err = queries.StartUserMFA(ctx, pgtype.Text{String: payload.UserID, Valid: true}, pgtype.Text{String: sealed, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is already enabled")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start enrollment")
+	}
+
+	uri := totp.URI(cfg.MFAIssuer, payload.Email, secret)
+	return c.Status(fiber.StatusCreated).JSON(TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRPayload:  uri,
+	})
+}
+
+// @Summary Confirm TOTP enrollment
+// @Description Enable two-factor authentication with a code from the authenticator app. The response holds the recovery codes, which are shown only once. Accounts required to use MFA have to sign in again with the new factor before using the admin API.
+// @Tags User
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body MFACodeRequest true "TOTP code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /users/me/mfa/totp/confirm [post]
+func confirmTOTPHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	pgUserID := pgtype.Text{String: payload.UserID, Valid: true}
+	mfa, err := queries.GetUserMFA(ctx, pgUserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "No TOTP enrollment in progress")
+	}
+	if mfa.ConfirmedAt.Valid {
+		return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is already enabled")
+	}
+	secret, err := auth.OpenKey(secretKey(keyLabelTOTPSeal), mfa.TotpSecret)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read secret")
+	}
+	step, ok := totp.Validate(string(secret), req.Code, time.Now(), totpSkew)
+	if !ok {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid code")
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Tx Error")
+	}
+	defer tx.Rollback(ctx)
+	qtx := queries.WithTx(tx)
+
+	confirmed, err := qtx.ConfirmUserMFA(ctx, pgUserID, pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}, pgtype.Int8{Int64: step, Valid: true})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to enable two-factor authentication")
+	}
+	if confirmed == 0 {
+		return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is already enabled")
+	}
+	codes, err := replaceRecoveryCodes(ctx, qtx, payload.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create recovery codes")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to enable two-factor authentication")
+	}
+	recordAuthEvent(ctx, c, payload.UserID, "MFA_ENABLED", fiber.Map{"factor": factorTOTP})
+
+	return c.JSON(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes of the current user; requires a TOTP code. The new codes are shown only once.
+// @Tags User
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body MFACodeRequest true "TOTP code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /users/me/mfa/recovery-codes [post]
+func regenerateRecoveryCodesHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	mfa, err := getConfirmedMFA(ctx, queries, payload.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load MFA settings")
+	}
+	if mfa == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Two-factor authentication is not enabled")
+	}
+	if err := checkLoginBlocked(ctx, c, payload.Email); err != nil {
+		return err
+	}
+	ok, err := checkTOTP(ctx, queries, mfa, req.Code)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify code")
+	}
+	if !ok {
+		recordLoginFailure(ctx, c, payload.Email, payload.UserID, "invalid_"+factorTOTP)
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid code")
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Tx Error")
+	}
+	defer tx.Rollback(ctx)
+
+	codes, err := replaceRecoveryCodes(ctx, queries.WithTx(tx), payload.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create recovery codes")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create recovery codes")
+	}
+	recordAuthEvent(ctx, c, payload.UserID, "MFA_RECOVERY_CODES_REGENERATED", nil)
+
+	return c.JSON(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Disable MFA
+// @Description Turn off TOTP for the current user with a TOTP code or a recovery code. Passkeys are removed one by one under /users/me/passkeys.
+// @Tags User
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /users/me/mfa [delete]
+func disableMFAHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	mfa, err := getConfirmedMFA(ctx, queries, payload.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load MFA settings")
+	}
+	if mfa == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Two-factor authentication is not enabled")
+	}
+	// Wrong codes count as failed logins so that a stolen access token
+	// cannot be used to guess its way to turning MFA off
+	if err := checkLoginBlocked(ctx, c, payload.Email); err != nil {
+		return err
+	}
+	factor, ok, err := checkSecondFactor(ctx, queries, mfa, req.Code, req.RecoveryCode)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify code")
+	}
+	if !ok {
+		recordLoginFailure(ctx, c, payload.Email, payload.UserID, "invalid_"+factor)
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid code")
+	}
+
+	if err := removeMFA(ctx, payload.UserID, false); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to disable two-factor authentication")
+	}
+	recordAuthEvent(ctx, c, payload.UserID, "MFA_DISABLED", nil)
+
+	return c.JSON(fiber.Map{"status": "mfa_disabled"})
+}
+
+// removeMFA deletes the TOTP secret and the recovery codes of a user, and
+// with passkeys their WebAuthn credentials as well
+func removeMFA(ctx context.Context, userID string, passkeys bool) error {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	qtx := queries.WithTx(tx)
+
+	pgUserID := pgtype.Text{String: userID, Valid: true}
+	if err := qtx.DeleteUserMFA(ctx, pgUserID); err != nil {
+		return err
+	}
+	if err := qtx.DeleteRecoveryCodes(ctx, pgUserID); err != nil {
+		return err
+	}
+	if passkeys {
+		if err := qtx.DeleteUserWebAuthnCredentials(ctx, pgUserID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// @Summary Reset user MFA
+// @Description Remove a user's TOTP secret, recovery codes and passkeys, e.g. after a lost phone, and revoke all of their sessions. The user signs in with the password alone and can enroll again.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/users/{id}/mfa/reset [post]
+func resetUserMFAHandler(c *fiber.Ctx) error {
+	ctx := context.Background()
+	user, err := queries.GetUserByID(ctx, pgtype.Text{String: c.Params("id"), Valid: true})
+	if err != nil || user.DeletedAt.Valid {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+	if err := checkCanManageUser(ctx, c, user.ID); err != nil {
+		return err
+	}
+
+	if err := removeMFA(ctx, user.ID, true); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to reset two-factor authentication")
+	}
+	if err := revokeUserSessions(ctx, queries, user.ID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	recordAuthEvent(ctx, c, user.ID, "MFA_RESET_BY_ADMIN", nil)
+
+	return c.JSON(fiber.Map{"status": "mfa_reset"})
+}
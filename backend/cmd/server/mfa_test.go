@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/totp"
+)
+
+// newTOTPUser enrolls userID with a new TOTP secret and keeps last_used_step
+// like the user_mfa table does
+func newTOTPUser(t *testing.T, s *testServer, userID string) (*db.UserMfa, string) {
+	t.Helper()
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := auth.SealKey(secretKey(keyLabelTOTPSeal), []byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa := &db.UserMfa{UserID: userID, TotpSecret: sealed, ConfirmedAt: pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}}
+
+	// Same condition as the WHERE clause of the query
+	s.db.Handle("UseTOTPStep", func(args ...any) (any, error) {
+		step := args[1].(pgtype.Int8).Int64
+		if args[0].(pgtype.Text).String != mfa.UserID || mfa.LastUsedStep >= step {
+			return int64(0), nil
+		}
+		mfa.LastUsedStep = step
+		return int64(1), nil
+	})
+	return mfa, secret
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestCheckTOTP(t *testing.T) {
+	s := newTestServer(t)
+	mfa, secret := newTOTPUser(t, s, "alice")
+	ctx := context.Background()
+	step := totp.Step(time.Now())
+
+	check := func(name, code string, want bool) {
+		t.Helper()
+		ok, err := checkTOTP(ctx, queries, mfa, code)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if ok != want {
+			t.Errorf("%s: got %v, want %v", name, ok, want)
+		}
+	}
+
+	check("previous step", totpCode(t, secret, step-1), true)
+	check("replayed", totpCode(t, secret, step-1), false)
+	check("current step", totpCode(t, secret, step), true)
+	check("replayed after a later code", totpCode(t, secret, step), false)
+	check("earlier step after a later code", totpCode(t, secret, step-1), false)
+	check("next step", totpCode(t, secret, step+1), true)
+	check("out of skew", totpCode(t, secret, step+3), false)
+	check("wrong code", "abcdef", false)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	s := newTestServer(t)
+	mfa, _ := newTOTPUser(t, s, "alice")
+	ctx := context.Background()
+
+	// code hash -> used
+	stored := map[string]bool{}
+	s.db.Handle("DeleteRecoveryCodes", func(args ...any) (any, error) {
+		clear(stored)
+		return nil, nil
+	})
+	s.db.Handle("CreateRecoveryCode", func(args ...any) (any, error) {
+		stored[args[1].(pgtype.Text).String] = false
+		return nil, nil
+	})
+	s.db.Handle("UseRecoveryCode", func(args ...any) (any, error) {
+		hash := args[1].(pgtype.Text).String
+		if used, ok := stored[hash]; !ok || used {
+			return int64(0), nil
+		}
+		stored[hash] = true
+		return int64(1), nil
+	})
+
+	codes, err := replaceRecoveryCodes(ctx, queries, mfa.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount || len(stored) != recoveryCodeCount {
+		t.Fatalf("got %d codes and %d stored, want %d", len(codes), len(stored), recoveryCodeCount)
+	}
+
+	use := func(name, code string, want bool) {
+		t.Helper()
+		factor, ok, err := checkSecondFactor(ctx, queries, mfa, "", code)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if factor != factorRecoveryCode || ok != want {
+			t.Errorf("%s: got (%s, %v), want (%s, %v)", name, factor, ok, factorRecoveryCode, want)
+		}
+	}
+
+	use("first use", codes[0], true)
+	use("second use", codes[0], false)
+	// Typed without the dash and in upper case
+	use("other code", strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")), true)
+	use("other code again", codes[1], false)
+	use("unknown code", "aaaaa-bbbbb", false)
+
+	// A new set invalidates the old codes
+	if _, err := replaceRecoveryCodes(ctx, queries, mfa.UserID); err != nil {
+		t.Fatal(err)
+	}
+	use("code of the previous set", codes[2], false)
+}
+
+// TestMFALoginFailures fails the second factor: signing in with the first
+// factor again does not reset the failed logins, only completing the login
+// does
+func TestMFALoginFailures(t *testing.T) {
+	s := newEmailLoginServer(t)
+	mfa, secret := newTOTPUser(t, s.testServer, "alice")
+	s.db.Handle("GetUserMFA", func(args ...any) (any, error) {
+		if args[0].(pgtype.Text).String != mfa.UserID {
+			return nil, nil
+		}
+		return *mfa, nil
+	})
+	s.app.Post("/mfa/verify", verifyMFAHandler)
+	key := emailAttemptKey("alice@example.com")
+
+	var challenge MFAChallengeResponse
+	if status := s.call(t, "/login", "", LoginRequest{Email: "alice@example.com", Password: "correct horse"}, &challenge); status != fiber.StatusOK || !challenge.MFARequired {
+		t.Fatalf("password login: got (%d, %+v), want an MFA challenge", status, challenge)
+	}
+	if status := s.call(t, "/mfa/verify", "", MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: "abcdef"}, nil); status != fiber.StatusUnauthorized {
+		t.Fatalf("wrong code: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	s.elapse(s.delay(key))
+
+	if status := s.call(t, "/login", "", LoginRequest{Email: "alice@example.com", Password: "correct horse"}, &challenge); status != fiber.StatusOK {
+		t.Fatalf("second password login: got %d, want %d", status, fiber.StatusOK)
+	}
+	code, _ := s.requestLogin(t)
+	if status := s.call(t, "/email-login/verify", "", EmailLoginVerifyRequest{Email: "alice@example.com", Code: code}, nil); status != fiber.StatusOK {
+		t.Fatalf("email login: got %d, want %d", status, fiber.StatusOK)
+	}
+	if attempt, ok := s.attempts[key]; !ok || attempt.failures != 1 {
+		t.Fatalf("a first factor reset the failed second factor: %+v", attempt)
+	}
+
+	if status := s.call(t, "/mfa/verify", "", MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: totpCode(t, secret, totp.Step(time.Now()))}, nil); status != fiber.StatusOK {
+		t.Fatalf("right code: got %d, want %d", status, fiber.StatusOK)
+	}
+	if _, ok := s.attempts[key]; ok {
+		t.Error("completing the login did not clear the failed logins")
+	}
+}
+
+// TestMFAGuardTruncatedClaims signs in with a password alone to an account
+// whose permission requiring MFA did not fit into the token
+func TestMFAGuardTruncatedClaims(t *testing.T) {
+	s := newTestServer(t)
+	s.addUser("alice", "alice@example.com")
+	cfg.TokenMaxClaims = 1
+	cfg.MFARequiredPermissions = []string{"users:delete"}
+	s.db.Handle("GetUserRoles", func(args ...any) (any, error) {
+		return []db.GetUserRolesRow{{ID: 1, Name: "staff"}}, nil
+	})
+	s.db.Handle("GetUserPermissions", func(args ...any) (any, error) {
+		return []db.GetUserPermissionsRow{{ID: 1, Slug: "users:delete"}}, nil
+	})
+	// Alice has a passkey, so she is not asked to enroll
+	s.db.Handle("CountWebAuthnCredentials", func(args ...any) (any, error) { return int64(1), nil })
+	s.app.Post("/admin", authMiddleware, mfaEnrollmentGuard, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	tokens := s.signIn(t, "alice")
+	if payload, err := tokenMaker.VerifyToken(tokens.AccessToken); err != nil || !payload.ClaimsTruncated || len(payload.Permissions) != 0 {
+		t.Fatalf("got (%+v, %v), want a token without the permission", payload, err)
+	}
+	if status := s.call(t, "/admin", tokens.AccessToken, nil, nil); status != fiber.StatusForbidden {
+		t.Errorf("got %d, want %d", status, fiber.StatusForbidden)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/oauth"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+// oauthStatePurpose prefixes the verification_tokens identifier of OAuth
+// logins in progress ("oauth-state:<provider>:<login id>"). The token is the
+// state parameter, so every state is issued by us and used once.
+const oauthStatePurpose = "oauth-state"
+
+// oauthHTTPTimeout bounds every request to an identity provider
+const oauthHTTPTimeout = 10 * time.Second
+
+// oauthProviders are the configured identity providers by name
+var oauthProviders map[string]oauth.Provider
+
+type OAuthURLResponse struct {
+	URL string `json:"url"`
+	// State comes back as a query parameter of the redirect and has to be
+	// passed to the callback
+	State string `json:"state"`
+}
+
+type OAuthCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// setupOAuthProviders creates the identity providers from configuration
+func setupOAuthProviders() error {
+	client := &http.Client{Timeout: oauthHTTPTimeout}
+	oauthProviders = map[string]oauth.Provider{}
+	for _, p := range cfg.OAuthProviders {
+		provider, err := oauth.New(oauth.Config{
+			Name:         p.Name,
+			Type:         p.Type,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+			TrustEmail:   p.TrustEmail,
+			AuthURL:      p.AuthURL,
+			TokenURL:     p.TokenURL,
+			APIURL:       p.APIURL,
+			HTTPClient:   client,
+		})
+		if err != nil {
+			return err
+		}
+		oauthProviders[p.Name] = provider
+	}
+	return nil
+}
+
+// lookupOAuthProvider returns the provider named in the route
+func lookupOAuthProvider(c *fiber.Ctx) (oauth.Provider, error) {
+	provider, ok := oauthProviders[c.Params("provider")]
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Unknown OAuth provider")
+	}
+	return provider, nil
+}
+
+// oauthSecrets derives the OIDC nonce and the PKCE verifier of a login from
+// its state, so that neither has to be stored. Both are 43 characters of
+// base64url as PKCE requires.
+func oauthSecrets(state string) (nonce, verifier string) {
+	derive := func(label string) string {
+		mac := hmac.New(sha256.New, secretKey(keyLabelOAuthState))
+		mac.Write([]byte(label + ":" + state))
+		return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return derive("oauth-nonce"), derive("oauth-pkce")
+}
+
+// @Summary Get OAuth URL
+// @Description Start a login at an identity provider (google, github, cloudflare or any provider configured in OAUTH_PROVIDERS). Redirect the browser to url; the provider sends it back to the redirect URL with code and state.
+// @Tags Auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} OAuthURLResponse
+// @Failure 404 {object} map[string]interface{}
+// @Failure 502 {object} map[string]interface{}
+// @Router /auth/oauth/{provider}/url [get]
+func oauthURLHandler(c *fiber.Ctx) error {
+	provider, err := lookupOAuthProvider(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	loginID, err := utils.GenerateID()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate ID")
+	}
+	state, err := createVerificationToken(ctx, queries, oauthStatePurpose, provider.Name()+":"+loginID, cfg.OAuthStateTTL)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start OAuth login")
+	}
+
+	nonce, verifier := oauthSecrets(state)
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Printf("OAuth login with %s failed: %v", provider.Name(), err)
+		return fiber.NewError(fiber.StatusBadGateway, "Identity provider is unavailable")
+	}
+	return c.JSON(OAuthURLResponse{URL: authURL, State: state})
+}
+
+// @Summary OAuth callback
+// @Description Finish a login at an identity provider with the code and state of the redirect. Unknown verified email addresses get a new account.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param request body OAuthCallbackRequest true "OAuth Callback Request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /auth/oauth/{provider}/callback [post]
+func oauthCallbackHandler(c *fiber.Ctx) error {
+	provider, err := lookupOAuthProvider(c)
+	if err != nil {
+		return err
+	}
+	var req OAuthCallbackRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" || req.State == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	subject, err := consumeVerificationToken(ctx, queries, oauthStatePurpose, req.State)
+	if name, _, _ := strings.Cut(subject, ":"); err != nil || name != provider.Name() {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired OAuth state")
+	}
+
+	nonce, verifier := oauthSecrets(req.State)
+	identity, err := provider.Exchange(ctx, req.Code, nonce, verifier)
+	if errors.Is(err, oauth.ErrNoVerifiedEmail) {
+		recordAuthEvent(ctx, c, "", "FAILED_LOGIN", fiber.Map{"provider": provider.Name(), "reason": "email_not_verified"})
+		return fiber.NewError(fiber.StatusBadRequest, "Could not retrieve a verified email from "+provider.Name())
+	}
+	if err != nil {
+		log.Printf("OAuth login with %s failed: %v", provider.Name(), err)
+		recordAuthEvent(ctx, c, "", "FAILED_LOGIN", fiber.Map{"provider": provider.Name(), "reason": "oauth_exchange"})
+		return fiber.NewError(fiber.StatusUnauthorized, "OAuth login failed")
+	}
+
+	user, err := findOrCreateOAuthUser(ctx, c, provider.Name(), identity)
+	if err != nil {
+		return err
+	}
+	if err := checkAccountStatus(user.Status, user.StatusExpiresAt); err != nil {
+		recordAuthEvent(ctx, c, user.ID, "FAILED_LOGIN", fiber.Map{"provider": provider.Name(), "reason": "account_" + user.Status})
+		return err
+	}
+
+	return completeLogin(ctx, c, user.ID, "", newSessionUserByEmail(user), auth.AMRFederated, "OAUTH_LOGIN", fiber.Map{"provider": provider.Name()})
+}
+
+// findOrCreateOAuthUser returns the account with the identity's email,
+// registering it on first login. The provider vouched for the address, so
+// new accounts start out verified.
+func findOrCreateOAuthUser(ctx context.Context, c *fiber.Ctx, provider string, identity *oauth.Identity) (db.GetUserByEmailRow, error) {
+	pgEmail := pgtype.Text{String: identity.Email, Valid: true}
+	user, err := queries.GetUserByEmail(ctx, pgEmail)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return user, fiber.NewError(fiber.StatusInternalServerError, "Failed to load user")
+	}
+
+	id, err := utils.GenerateID()
+	if err != nil {
+		return user, fiber.NewError(fiber.StatusInternalServerError, "Failed to generate ID")
+	}
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	newUser, err := queries.CreateUser(ctx,
+		pgtype.Text{String: id, Valid: true},
+		pgtype.Text{String: name, Valid: true},
+		pgEmail,
+		pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+		pgtype.Text{String: identity.Picture, Valid: identity.Picture != ""},
+		pgtype.Text{Valid: false},
+	)
+	if err != nil {
+		return user, fiber.NewError(fiber.StatusInternalServerError, "Failed to create user")
+	}
+	user = db.GetUserByEmailRow{
+		ID:            newUser.ID,
+		Name:          newUser.Name,
+		Email:         newUser.Email,
+		EmailVerified: newUser.EmailVerified,
+		Image:         newUser.Image,
+		Password:      newUser.Password,
+		CreatedAt:     newUser.CreatedAt,
+		UpdatedAt:     newUser.UpdatedAt,
+	}
+	recordAuthEvent(ctx, c, user.ID, "REGISTER", fiber.Map{"method": "oauth", "provider": provider})
+	return user, nil
+}
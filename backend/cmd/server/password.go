@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+// resetPasswordPurpose prefixes the verification_tokens identifier of
+// password reset tokens ("reset-password:<user id>")
+const resetPasswordPurpose = "reset-password"
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// sendPasswordResetEmail issues a password reset token for the user and
+// mails a link to the frontend reset form
+func sendPasswordResetEmail(ctx context.Context, userID, email, name, locale string) error {
+	token, err := createVerificationToken(ctx, queries, resetPasswordPurpose, userID, cfg.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return deliverMail(email, "reset_password", locale, fiber.Map{
+		"Name":      name,
+		"Link":      cfg.FrontendURL + "/reset-password?token=" + url.QueryEscape(token),
+		"ExpiresIn": cfg.PasswordResetTTL,
+	})
+}
+
+// @Summary Forgot password
+// @Description Email a password reset link. The response is the same whether or not the account exists.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Forgot Password Request"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/password/forgot [post]
+func forgotPasswordHandler(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil || !utils.ValidateEmail(req.Email) {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	user, err := queries.GetUserByEmail(ctx, pgtype.Text{String: req.Email, Valid: true})
+	if err == nil {
+		if err := sendPasswordResetEmail(ctx, user.ID, user.Email.String, user.Name.String, c.Get(fiber.HeaderAcceptLanguage)); err != nil {
+			log.Printf("Failed to send password reset email to %s: %v", user.Email.String, err)
+		}
+		recordAuthEvent(ctx, c, user.ID, "PASSWORD_RESET_REQUEST", nil)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status": "If the account exists, a password reset email has been sent",
+	})
+}
+
+// @Summary Reset password
+// @Description Set a new password with a reset token. The token can be used once, and every existing session of the user is revoked.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset Password Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/password/reset [post]
+func resetPasswordHandler(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	isValidPass, passMsg := utils.ValidatePassword(req.Password)
+	if !isValidPass {
+		return fiber.NewError(fiber.StatusBadRequest, passMsg)
+	}
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to hash password")
+	}
+
+	ctx := context.Background()
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Tx Error")
+	}
+	defer tx.Rollback(ctx)
+	qtx := queries.WithTx(tx)
+
+	userID, err := consumeVerificationToken(ctx, qtx, resetPasswordPurpose, req.Token)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired reset token")
+	}
+
+	pgUserID := pgtype.Text{String: userID, Valid: true}
+	if err := qtx.UpdateUserPassword(ctx, pgUserID, pgtype.Text{String: hashedPassword, Valid: true}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update password")
+	}
+	if err := revokeUserSessions(ctx, qtx, userID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke sessions")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update password")
+	}
+	recordAuthEvent(ctx, c, userID, "PASSWORD_RESET", nil)
+	return c.JSON(fiber.Map{"status": "password_reset"})
+}
+
+// passwordChangeGuard rejects tokens of accounts that must change their
+// password first, e.g. after an admin reset. It runs after authMiddleware.
+func passwordChangeGuard(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	if payload.PasswordChangeRequired {
+		return fiber.NewError(fiber.StatusForbidden, "Password change required")
+	}
+	return c.Next()
+}
+
+// @Summary Change password
+// @Description Change the password of the current user. Requires a sign-in within STEP_UP_MAX_AGE. Every other session is signed out; the response carries a new token pair for this device.
+// @Tags User
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body ChangePasswordRequest true "Change Password Request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /users/me/password [put]
+func changePasswordHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	var req ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid body")
+	}
+
+	ctx := context.Background()
+	pgUserID := pgtype.Text{String: payload.UserID, Valid: true}
+	user, err := queries.GetUserByID(ctx, pgUserID)
+	if err != nil || user.DeletedAt.Valid {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+	if !user.Password.Valid {
+		return fiber.NewError(fiber.StatusBadRequest, "Account has no password, use password reset to set one")
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, user.Password.String) {
+		return fiber.NewError(fiber.StatusUnauthorized, "Current password is incorrect")
+	}
+
+	isValidPass, passMsg := utils.ValidatePassword(req.NewPassword)
+	if !isValidPass {
+		return fiber.NewError(fiber.StatusBadRequest, passMsg)
+	}
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to hash password")
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Tx Error")
+	}
+	defer tx.Rollback(ctx)
+	qtx := queries.WithTx(tx)
+
+	if err := qtx.UpdateUserPassword(ctx, pgUserID, pgtype.Text{String: hashedPassword, Valid: true}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update password")
+	}
+	// Sign out everywhere, then start a fresh session for the caller
+	if err := revokeUserSessions(ctx, qtx, user.ID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	// The new session inherits how the caller signed in, a password change
+	// is no substitute for their second factor
+	tokens, err := issueSession(ctx, qtx, user.ID, "", authentication{time: payload.AuthTime, methods: payload.AMR})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create session")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update password")
+	}
+	recordAuthEvent(ctx, c, user.ID, "PASSWORD_CHANGE", nil)
+	user.MustChangePassword = false
+
+	return c.JSON(sessionResponse(tokens, newSessionUser(user)))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/skoservice-authenserver/internal/middleware"
+	"github.com/yourusername/skoservice-authenserver/internal/ratelimit"
+)
+
+var rateLimitStore ratelimit.Store
+
+// setupRateLimiter creates the configured token bucket store
+func setupRateLimiter() error {
+	var err error
+	rateLimitStore, err = ratelimit.New(ratelimit.Config{
+		Backend:       cfg.RateLimitBackend,
+		RedisURL:      cfg.RedisURL,
+		RedisPassword: cfg.RedisPassword,
+		RedisDB:       cfg.RedisDB,
+	})
+	return err
+}
+
+// rateLimiter limits a route group to max requests per period and client
+func rateLimiter(name string, max int, period time.Duration, keyFunc func(c *fiber.Ctx) string) fiber.Handler {
+	return middleware.RateLimit(middleware.RateLimitConfig{
+		Name:    name,
+		Store:   rateLimitStore,
+		Limit:   ratelimit.Limit{Max: max, Period: period},
+		KeyFunc: keyFunc,
+	})
+}
+
+// authRateLimiter is the tight per-IP limit of /auth/*. Introspection is
+// called by resource servers on behalf of many users and has its own limit.
+func authRateLimiter() fiber.Handler {
+	return middleware.RateLimit(middleware.RateLimitConfig{
+		Name:    "auth",
+		Store:   rateLimitStore,
+		Limit:   ratelimit.Limit{Max: cfg.RateLimitAuthMax, Period: cfg.RateLimitAuthDuration},
+		KeyFunc: middleware.KeyByIP,
+		Next: func(c *fiber.Ctx) bool {
+			return strings.HasSuffix(c.Path(), "/introspect")
+		},
+	})
+}
+
+// keyByServiceClient limits each service client (API key). It runs after
+// serviceClientMiddleware.
+func keyByServiceClient(c *fiber.Ctx) string {
+	if clientID, ok := c.Locals("service_client").(string); ok {
+		return "client:" + clientID
+	}
+	return middleware.KeyByIP(c)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SessionTokens is the token pair handed out whenever a user signs in or
+// refreshes. The refresh token is opaque; only its hash is stored.
+type SessionTokens struct {
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+	TokenType             string    `json:"token_type"`
+}
+
+// authentication records when and how the user of a session signed in. It
+// becomes the auth_time and amr claims and survives refreshes, so that a
+// refreshed token does not look like a fresh login.
+type authentication struct {
+	time    time.Time
+	methods []string
+}
+
+// newAuthentication is a sign-in that just happened with the given methods
+// (auth.AMR* values)
+func newAuthentication(methods ...string) authentication {
+	return authentication{time: time.Now().UTC(), methods: methods}
+}
+
+// issueSession creates a short-lived access token and a new refresh token.
+// An empty familyID starts a new token family (a fresh login); refreshes pass
+// the family of the token being rotated.
+func issueSession(ctx context.Context, q *db.Queries, userID, familyID string, authn authentication) (*SessionTokens, error) {
+	var err error
+	if familyID == "" {
+		if familyID, err = utils.GenerateID(); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := q.GetUserByID(ctx, pgtype.Text{String: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	roles, permissions, err := loadUserAccess(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	factors, err := secondFactors(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	claims := auth.Claims{
+		UserID:                 userID,
+		Email:                  user.Email.String,
+		Roles:                  roles,
+		Permissions:            permissions,
+		SessionID:              familyID,
+		PasswordChangeRequired: user.MustChangePassword,
+		MFAEnrollmentRequired:  len(factors) == 0 && requiresMFA(roles, permissions),
+		AuthTime:               authn.time,
+		AMR:                    authn.methods,
+	}
+	claims.Limit(cfg.TokenMaxClaims)
+
+	accessToken, payload, err := tokenMaker.CreateToken(claims, cfg.AccessTokenDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := utils.GenerateID()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().UTC().Add(cfg.RefreshTokenDuration)
+
+	_, err = q.CreateRefreshSession(ctx,
+		pgtype.Text{String: sessionID, Valid: true},
+		pgtype.Text{String: utils.HashToken(refreshToken), Valid: true},
+		pgtype.Text{String: userID, Valid: true},
+		pgtype.Timestamp{Time: expires, Valid: true},
+		pgtype.Text{String: familyID, Valid: true},
+		pgtype.Timestamp{Time: authn.time, Valid: !authn.time.IsZero()},
+		authn.methods,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionTokens{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  payload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: expires,
+		TokenType:             "Bearer",
+	}, nil
+}
+
+// loadUserAccess returns the names of the user's roles and the slugs of the
+// permissions granted through them
+func loadUserAccess(ctx context.Context, q *db.Queries, userID string) ([]string, []string, error) {
+	pgUserID := pgtype.Text{String: userID, Valid: true}
+	roleRows, err := q.GetUserRoles(ctx, pgUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	permRows, err := q.GetUserPermissions(ctx, pgUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roles := make([]string, 0, len(roleRows))
+	for _, r := range roleRows {
+		roles = append(roles, r.Name)
+	}
+	permissions := make([]string, 0, len(permRows))
+	for _, p := range permRows {
+		permissions = append(permissions, p.Slug)
+	}
+	return roles, permissions, nil
+}
+
+// SessionUser is the account returned with a session, without the password
+// hash and the status details only admins see
+type SessionUser struct {
+	ID                 string           `json:"id"`
+	Name               pgtype.Text      `json:"name"`
+	Email              pgtype.Text      `json:"email"`
+	EmailVerified      pgtype.Timestamp `json:"email_verified"`
+	Image              pgtype.Text      `json:"image"`
+	CreatedAt          pgtype.Timestamp `json:"created_at"`
+	UpdatedAt          pgtype.Timestamp `json:"updated_at"`
+	MustChangePassword bool             `json:"must_change_password"`
+}
+
+func newSessionUser(user db.GetUserByIDRow) SessionUser {
+	return SessionUser{
+		ID:                 user.ID,
+		Name:               user.Name,
+		Email:              user.Email,
+		EmailVerified:      user.EmailVerified,
+		Image:              user.Image,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+		MustChangePassword: user.MustChangePassword,
+	}
+}
+
+func newSessionUserByEmail(user db.GetUserByEmailRow) SessionUser {
+	return SessionUser{
+		ID:                 user.ID,
+		Name:               user.Name,
+		Email:              user.Email,
+		EmailVerified:      user.EmailVerified,
+		Image:              user.Image,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+		MustChangePassword: user.MustChangePassword,
+	}
+}
+
+// sessionResponse is the body returned by login, OAuth callbacks and refresh
+func sessionResponse(tokens *SessionTokens, user SessionUser) fiber.Map {
+	return fiber.Map{
+		"access_token":             tokens.AccessToken,
+		"access_token_expires_at":  tokens.AccessTokenExpiresAt,
+		"refresh_token":            tokens.RefreshToken,
+		"refresh_token_expires_at": tokens.RefreshTokenExpiresAt,
+		"token_type":               tokens.TokenType,
+		"user":                     user,
+	}
+}
+
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and a rotated refresh token. Replaying an already rotated refresh token revokes the whole session.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh Request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /auth/refresh [post]
+func refreshHandler(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Tx Error")
+	}
+	defer tx.Rollback(ctx)
+	qtx := queries.WithTx(tx)
+
+	pgToken := pgtype.Text{String: utils.HashToken(req.RefreshToken), Valid: true}
+	session, err := qtx.GetRefreshSessionForUpdate(ctx, pgToken)
+	if err != nil || session.RevokedAt.Valid {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	if session.RotatedAt.Valid {
+		// The token was already exchanged once, so either the client or an
+		// attacker holds a stolen copy. Kill every token in the family.
+		if err := qtx.RevokeSessionFamily(ctx, session.FamilyID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke session")
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke session")
+		}
+		log.Printf("Refresh token reuse detected for user %s, revoked family %s", session.UserID, session.FamilyID.String)
+		recordAuthEvent(ctx, c, session.UserID, "REFRESH_TOKEN_REUSE", fiber.Map{"session_id": session.FamilyID.String})
+		return fiber.NewError(fiber.StatusUnauthorized, "Refresh token reuse detected, session revoked")
+	}
+
+	if time.Now().UTC().After(session.Expires.Time) {
+		return fiber.NewError(fiber.StatusUnauthorized, "Refresh token expired")
+	}
+
+	pgUserID := pgtype.Text{String: session.UserID, Valid: true}
+	user, err := qtx.GetUserByID(ctx, pgUserID)
+	if err != nil || user.DeletedAt.Valid {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+	if err := checkAccountStatus(user.Status, user.StatusExpiresAt); err != nil {
+		recordAuthEvent(ctx, c, user.ID, "FAILED_REFRESH", fiber.Map{"reason": "account_" + user.Status})
+		return err
+	}
+
+	if err := qtx.MarkSessionRotated(ctx, pgtype.Text{String: session.ID, Valid: true}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to rotate refresh token")
+	}
+
+	// Sessions created before sign-ins were recorded carry no auth_time and
+	// never pass a step-up check
+	authn := authentication{time: session.AuthTime.Time, methods: session.Amr}
+	tokens, err := issueSession(ctx, qtx, user.ID, session.FamilyID.String, authn)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create session")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to rotate refresh token")
+	}
+
+	return c.JSON(sessionResponse(tokens, newSessionUser(user)))
+}
+
+// isTokenRevoked reports whether a verified token was revoked individually
+// or by a user-wide revocation issued after it, or whether its account is
+// currently suspended, locked or pending
+func isTokenRevoked(ctx context.Context, payload *auth.Payload) (bool, error) {
+	revoked, err := queries.IsTokenRevoked(ctx,
+		pgtype.Text{String: payload.ID, Valid: true},
+		pgtype.Text{String: payload.UserID, Valid: true},
+		pgtype.Timestamp{Time: payload.IssuedAt.UTC(), Valid: true},
+		pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+	)
+	if err != nil {
+		return false, err
+	}
+	return revoked.Bool, nil
+}
+
+// revokeUserSessions signs a user out everywhere: refresh tokens are deleted
+// and every access token issued up to now stops being accepted.
+func revokeUserSessions(ctx context.Context, q *db.Queries, userID string) error {
+	pgUserID := pgtype.Text{String: userID, Valid: true}
+	if err := q.DeleteUserSessions(ctx, pgUserID); err != nil {
+		return err
+	}
+	return q.RevokeUserTokens(ctx, pgUserID, pgtype.Timestamp{Time: time.Now().UTC(), Valid: true})
+}
+
+// @Summary Logout
+// @Description Revoke the current access token and the refresh token session it belongs to
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body LogoutRequest false "Logout Request"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]interface{}
+// @Router /auth/logout [post]
+func logoutHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	var req LogoutRequest
+	// The body is optional, tokens issued before session IDs were embedded
+	// can still name their refresh token explicitly
+	_ = c.BodyParser(&req)
+
+	ctx := context.Background()
+	err := queries.RevokeToken(ctx,
+		pgtype.Text{String: payload.ID, Valid: true},
+		pgtype.Text{String: payload.UserID, Valid: true},
+		pgtype.Timestamp{Time: payload.ExpiredAt.UTC(), Valid: true},
+	)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke token")
+	}
+
+	if payload.SessionID != "" {
+		if err := queries.RevokeSessionFamily(ctx, pgtype.Text{String: payload.SessionID, Valid: true}); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke session")
+		}
+	}
+	if req.RefreshToken != "" {
+		if err := queries.DeleteSession(ctx, pgtype.Text{String: utils.HashToken(req.RefreshToken), Valid: true}); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke session")
+		}
+	}
+	recordAuthEvent(ctx, c, payload.UserID, "LOGOUT", fiber.Map{"session_id": payload.SessionID})
+
+	return c.JSON(fiber.Map{"status": "logged_out"})
+}
+
+// @Summary Logout from all devices
+// @Description Revoke every session and access token of the current user
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]interface{}
+// @Router /auth/logout-all [post]
+func logoutAllHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	ctx := context.Background()
+	if err := revokeUserSessions(ctx, queries, payload.UserID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	recordAuthEvent(ctx, c, payload.UserID, "LOGOUT", fiber.Map{"all_sessions": true})
+	return c.JSON(fiber.Map{"status": "logged_out"})
+}
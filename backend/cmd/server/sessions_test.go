@@ -0,0 +1,183 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+func newRefreshServer(t *testing.T) *testServer {
+	s := newTestServer(t)
+	s.app.Post("/refresh", refreshHandler)
+	s.addUser("alice", "alice@example.com")
+	return s
+}
+
+// refresh exchanges refreshToken and returns the status and the new tokens
+func (s *testServer) refresh(t *testing.T, refreshToken string) (int, *SessionTokens) {
+	t.Helper()
+	var tokens SessionTokens
+	status := s.call(t, "/refresh", "", RefreshRequest{RefreshToken: refreshToken}, &tokens)
+	return status, &tokens
+}
+
+func TestRefreshRotation(t *testing.T) {
+	s := newRefreshServer(t)
+	first := s.signIn(t, "alice")
+
+	status, second := s.refresh(t, first.RefreshToken)
+	if status != fiber.StatusOK {
+		t.Fatalf("refresh: got %d, want %d", status, fiber.StatusOK)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	payload, err := tokenMaker.VerifyToken(second.AccessToken)
+	if err != nil || payload.UserID != "alice" {
+		t.Errorf("access token: got (%+v, %v), want a token of alice", payload, err)
+	}
+
+	rotated := s.sessions[utils.HashToken(second.RefreshToken)]
+	if rotated.FamilyID != s.sessions[utils.HashToken(first.RefreshToken)].FamilyID {
+		t.Error("the rotated token started a new family")
+	}
+
+	status, third := s.refresh(t, second.RefreshToken)
+	if status != fiber.StatusOK {
+		t.Fatalf("second refresh: got %d, want %d", status, fiber.StatusOK)
+	}
+	if third.RefreshToken == second.RefreshToken {
+		t.Error("refresh token was not rotated again")
+	}
+}
+
+// TestRefreshReuse replays a rotated refresh token: the whole family is
+// revoked, including the token that replaced it, and other families are not
+func TestRefreshReuse(t *testing.T) {
+	s := newRefreshServer(t)
+	stolen := s.signIn(t, "alice")
+	other := s.signIn(t, "alice")
+
+	status, current := s.refresh(t, stolen.RefreshToken)
+	if status != fiber.StatusOK {
+		t.Fatalf("refresh: got %d, want %d", status, fiber.StatusOK)
+	}
+
+	if status, _ := s.refresh(t, stolen.RefreshToken); status != fiber.StatusUnauthorized {
+		t.Errorf("reused token: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	if status, _ := s.refresh(t, current.RefreshToken); status != fiber.StatusUnauthorized {
+		t.Errorf("token of the revoked family: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	if status, _ := s.refresh(t, other.RefreshToken); status != fiber.StatusOK {
+		t.Errorf("token of another family: got %d, want %d", status, fiber.StatusOK)
+	}
+}
+
+func TestRefreshInvalid(t *testing.T) {
+	s := newRefreshServer(t)
+	expired := s.signIn(t, "alice")
+	s.sessions[utils.HashToken(expired.RefreshToken)].Expires = pgtype.Timestamp{Time: time.Now().UTC().Add(-time.Minute), Valid: true}
+	s.addUser("bob", "bob@example.com")
+	deleted := s.signIn(t, "bob")
+	s.deleteUser("bob")
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"missing", "", fiber.StatusBadRequest},
+		{"unknown", "not-a-refresh-token", fiber.StatusUnauthorized},
+		{"expired", expired.RefreshToken, fiber.StatusUnauthorized},
+		{"deleted user", deleted.RefreshToken, fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := s.refresh(t, tt.token); status != tt.want {
+				t.Errorf("got %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+// TestSessionResponseUser signs in with a password: the account in the
+// response leaves out the password hash
+func TestSessionResponseUser(t *testing.T) {
+	s := newBruteForceServer(t)
+	var session struct {
+		User map[string]any `json:"user"`
+	}
+	if status := s.call(t, "/login", "", LoginRequest{Email: "alice@example.com", Password: "correct horse"}, &session); status != fiber.StatusOK {
+		t.Fatalf("login: got %d, want %d", status, fiber.StatusOK)
+	}
+	if session.User["id"] != "alice" {
+		t.Errorf("user: got %v, want alice", session.User)
+	}
+	if _, ok := session.User["password"]; ok {
+		t.Error("the response contains the password hash")
+	}
+}
+
+func newLogoutServer(t *testing.T) *testServer {
+	s := newRefreshServer(t)
+	s.app.Post("/logout", authMiddleware, logoutHandler)
+	s.app.Post("/logout-all", authMiddleware, logoutAllHandler)
+	s.app.Post("/protected", authMiddleware, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+	s.addUser("bob", "bob@example.com")
+	return s
+}
+
+func TestLogout(t *testing.T) {
+	s := newLogoutServer(t)
+	current := s.signIn(t, "alice")
+	other := s.signIn(t, "alice")
+
+	if status := s.call(t, "/logout", current.AccessToken, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("logout: got %d, want %d", status, fiber.StatusOK)
+	}
+	if status := s.call(t, "/protected", current.AccessToken, nil, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("revoked access token: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	if status, _ := s.refresh(t, current.RefreshToken); status != fiber.StatusUnauthorized {
+		t.Errorf("refresh token of the session: got %d, want %d", status, fiber.StatusUnauthorized)
+	}
+
+	// Other sessions stay signed in
+	if status := s.call(t, "/protected", other.AccessToken, nil, nil); status != fiber.StatusNoContent {
+		t.Errorf("access token of another session: got %d, want %d", status, fiber.StatusNoContent)
+	}
+	if status, _ := s.refresh(t, other.RefreshToken); status != fiber.StatusOK {
+		t.Errorf("refresh token of another session: got %d, want %d", status, fiber.StatusOK)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	s := newLogoutServer(t)
+	sessions := []*SessionTokens{s.signIn(t, "alice"), s.signIn(t, "alice")}
+	bob := s.signIn(t, "bob")
+
+	if status := s.call(t, "/logout-all", sessions[0].AccessToken, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("logout-all: got %d, want %d", status, fiber.StatusOK)
+	}
+	for i, tokens := range sessions {
+		if status := s.call(t, "/protected", tokens.AccessToken, nil, nil); status != fiber.StatusUnauthorized {
+			t.Errorf("access token %d: got %d, want %d", i, status, fiber.StatusUnauthorized)
+		}
+		if status, _ := s.refresh(t, tokens.RefreshToken); status != fiber.StatusUnauthorized {
+			t.Errorf("refresh token %d: got %d, want %d", i, status, fiber.StatusUnauthorized)
+		}
+	}
+	if status := s.call(t, "/protected", bob.AccessToken, nil, nil); status != fiber.StatusNoContent {
+		t.Errorf("access token of another user: got %d, want %d", status, fiber.StatusNoContent)
+	}
+
+	// Signing in again works
+	again := s.signIn(t, "alice")
+	if status := s.call(t, "/protected", again.AccessToken, nil, nil); status != fiber.StatusNoContent {
+		t.Errorf("access token issued afterwards: got %d, want %d", status, fiber.StatusNoContent)
+	}
+}
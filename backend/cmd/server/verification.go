@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+// verifyEmailPurpose prefixes the verification_tokens identifier of email
+// verification tokens ("verify-email:<user id>")
+const verifyEmailPurpose = "verify-email"
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// createVerificationToken issues a single-use token for purpose and user,
+// replacing any token of the same purpose that is still outstanding. Only the
+// hash of the token is stored.
+func createVerificationToken(ctx context.Context, q *db.Queries, purpose, userID string, ttl time.Duration) (string, error) {
+	identifier := pgtype.Text{String: purpose + ":" + userID, Valid: true}
+	if err := q.DeleteVerificationTokens(ctx, identifier); err != nil {
+		return "", err
+	}
+
+	token, err := utils.GenerateRandomString(43)
+	if err != nil {
+		return "", err
+	}
+	err = q.CreateVerificationToken(ctx,
+		identifier,
+		pgtype.Text{String: utils.HashToken(token), Valid: true},
+		pgtype.Timestamp{Time: time.Now().UTC().Add(ttl), Valid: true},
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// deleteEmailTokens discards the user's outstanding tokens that were mailed
+// to their email address: verification and password reset links and email
+// login codes and links
+func deleteEmailTokens(ctx context.Context, q *db.Queries, userID string) error {
+	for _, purpose := range []string{verifyEmailPurpose, resetPasswordPurpose, emailLoginPurpose, emailLoginCodePurpose} {
+		if err := q.DeleteVerificationTokens(ctx, pgtype.Text{String: purpose + ":" + userID, Valid: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumeVerificationToken redeems a token issued for purpose and returns the
+// ID of the user it belongs to. The token is deleted even if it has expired.
+func consumeVerificationToken(ctx context.Context, q *db.Queries, purpose, token string) (string, error) {
+	row, err := q.ConsumeVerificationToken(ctx,
+		pgtype.Text{String: utils.HashToken(token), Valid: true},
+		pgtype.Text{String: purpose, Valid: true},
+	)
+	if err != nil {
+		return "", err
+	}
+	if time.Now().UTC().After(row.Expires.Time) {
+		return "", errors.New("verification token expired")
+	}
+	return strings.TrimPrefix(row.Identifier, purpose+":"), nil
+}
+
+// sendVerificationEmail issues a new email verification token for the user
+// and mails the verification link
+func sendVerificationEmail(ctx context.Context, userID, email, name, locale string) error {
+	token, err := createVerificationToken(ctx, queries, verifyEmailPurpose, userID, cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return deliverMail(email, "verify_email", locale, fiber.Map{
+		"Name":      name,
+		"Link":      cfg.PublicURL + "/api/v1/auth/verify-email?token=" + url.QueryEscape(token),
+		"ExpiresIn": cfg.EmailVerificationTTL,
+	})
+}
+
+// @Summary Verify email address
+// @Description Redeem an email verification token, either from the emailed link (GET with token query) or posted by a client (POST with token body)
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param token query string false "Verification token (GET)"
+// @Param request body VerifyEmailRequest false "Verify Email Request (POST)"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/verify-email [get]
+// @Router /auth/verify-email [post]
+func verifyEmailHandler(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		var req VerifyEmailRequest
+		if err := c.BodyParser(&req); err != nil || req.Token == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Missing verification token")
+		}
+		token = req.Token
+	}
+
+	ctx := context.Background()
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Tx Error")
+	}
+	defer tx.Rollback(ctx)
+	qtx := queries.WithTx(tx)
+
+	userID, err := consumeVerificationToken(ctx, qtx, verifyEmailPurpose, token)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired verification token")
+	}
+
+	_, err = qtx.UpdateUser(ctx,
+		pgtype.Text{String: userID, Valid: true},
+		pgtype.Text{Valid: false},
+		pgtype.Text{Valid: false},
+		pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+		pgtype.Text{Valid: false},
+	)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify email")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify email")
+	}
+	recordAuthEvent(ctx, c, userID, "EMAIL_VERIFIED", nil)
+	return c.JSON(fiber.Map{"status": "verified"})
+}
+
+// @Summary Resend verification email
+// @Description Send a new verification link to an unverified account. The response is the same whether or not the account exists.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body ResendVerificationRequest true "Resend Verification Request"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/verify-email/resend [post]
+func resendVerificationHandler(c *fiber.Ctx) error {
+	var req ResendVerificationRequest
+	if err := c.BodyParser(&req); err != nil || !utils.ValidateEmail(req.Email) {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+	user, err := queries.GetUserByEmail(ctx, pgtype.Text{String: req.Email, Valid: true})
+	if err == nil && !user.EmailVerified.Valid {
+		if err := sendVerificationEmail(ctx, user.ID, user.Email.String, user.Name.String, c.Get(fiber.HeaderAcceptLanguage)); err != nil {
+			log.Printf("Failed to send verification email to %s: %v", user.Email.String, err)
+		}
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status": "If the account exists and is not verified yet, a verification email has been sent",
+	})
+}
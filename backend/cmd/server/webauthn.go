@@ -0,0 +1,538 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+// WebAuthn ceremonies as stored in webauthn_sessions
+const (
+	ceremonyRegistration = "registration"
+	// ceremonyLogin is a passwordless login with a discoverable credential
+	ceremonyLogin = "login"
+	// ceremonyMFA uses a passkey as the second factor of an MFA challenge
+	ceremonyMFA = "mfa"
+)
+
+const passkeyNameMaxLength = 100
+
+var webAuthn *webauthn.WebAuthn
+
+type PasskeyRegisterRequest struct {
+	SessionID string `json:"session_id"`
+	Name      string `json:"name"`
+	// Credential is the PublicKeyCredential returned by
+	// navigator.credentials.create(), JSON encoded
+	Credential json.RawMessage `json:"credential"`
+}
+
+type PasskeyLoginBeginRequest struct {
+	// MFAToken is set to use the passkey as the second factor of a login;
+	// without it the passkey is the only factor
+	MFAToken string `json:"mfa_token"`
+}
+
+type PasskeyLoginFinishRequest struct {
+	SessionID string `json:"session_id"`
+	MFAToken  string `json:"mfa_token"`
+	// Credential is the PublicKeyCredential returned by
+	// navigator.credentials.get(), JSON encoded
+	Credential json.RawMessage `json:"credential"`
+}
+
+// WebAuthnCeremonyResponse starts a ceremony: Options go to the browser's
+// WebAuthn API and SessionID comes back with its result
+type WebAuthnCeremonyResponse struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+type PasskeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Transports []string   `json:"transports"`
+	Synced     bool       `json:"synced"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func newPasskeyResponse(row db.WebauthnCredential) PasskeyResponse {
+	resp := PasskeyResponse{
+		ID:         row.ID,
+		Name:       row.Name,
+		Transports: row.Transports,
+		Synced:     row.BackupState,
+		CreatedAt:  row.CreatedAt.Time,
+	}
+	if row.LastUsedAt.Valid {
+		resp.LastUsedAt = &row.LastUsedAt.Time
+	}
+	return resp
+}
+
+// setupWebAuthn configures the relying party passkeys are registered with
+func setupWebAuthn() error {
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: cfg.WebAuthnTimeout, TimeoutUVD: cfg.WebAuthnTimeout}
+
+	var err error
+	webAuthn, err = webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnOrigins,
+		Timeouts:      webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	return err
+}
+
+// webAuthnUser adapts an account and its passkeys to webauthn.User. The user
+// handle is the user ID, which is random and carries no personal data.
+type webAuthnUser struct {
+	id          string
+	email       string
+	name        string
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.id)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.name != "" {
+		return u.name
+	}
+	return u.email
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// loadWebAuthnUser loads a user that has not been deleted, with their passkeys
+func loadWebAuthnUser(ctx context.Context, userID string) (*webAuthnUser, error) {
+	pgUserID := pgtype.Text{String: userID, Valid: true}
+	user, err := queries.GetUserByID(ctx, pgUserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt.Valid {
+		return nil, errors.New("user is deleted")
+	}
+	rows, err := queries.ListWebAuthnCredentials(ctx, pgUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &webAuthnUser{id: user.ID, email: user.Email.String, name: user.Name.String}
+	for _, row := range rows {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(row.Transports))
+		for _, transport := range row.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+		u.credentials = append(u.credentials, webauthn.Credential{
+			ID:              row.CredentialID,
+			PublicKey:       row.PublicKey,
+			AttestationType: row.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: row.BackupEligible,
+				BackupState:    row.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    row.Aaguid,
+				SignCount: uint32(row.SignCount),
+			},
+		})
+	}
+	return u, nil
+}
+
+// saveWebAuthnSession keeps the state of a started ceremony until the
+// browser answers, and returns the handle the client sends back with it
+func saveWebAuthnSession(ctx context.Context, ceremony, userID string, session *webauthn.SessionData) (string, error) {
+	handle, err := utils.GenerateRandomString(43)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	err = queries.CreateWebAuthnSession(ctx,
+		pgtype.Text{String: utils.HashToken(handle), Valid: true},
+		pgtype.Text{String: userID, Valid: userID != ""},
+		pgtype.Text{String: ceremony, Valid: true},
+		data,
+		pgtype.Timestamp{Time: time.Now().UTC().Add(cfg.WebAuthnTimeout), Valid: true},
+	)
+	if err != nil {
+		return "", err
+	}
+	return handle, nil
+}
+
+// consumeWebAuthnSession redeems a ceremony handle and returns the user the
+// ceremony was started for (empty for passwordless logins) and its state.
+// Every handle can be used once, even if the ceremony fails.
+func consumeWebAuthnSession(ctx context.Context, ceremony, handle string) (string, *webauthn.SessionData, error) {
+	row, err := queries.ConsumeWebAuthnSession(ctx,
+		pgtype.Text{String: utils.HashToken(handle), Valid: true},
+		pgtype.Text{String: ceremony, Valid: true},
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	if time.Now().UTC().After(row.ExpiresAt.Time) {
+		return "", nil, errors.New("webauthn session expired")
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(row.Data, &session); err != nil {
+		return "", nil, err
+	}
+	return row.UserID.String, &session, nil
+}
+
+// recordPasskeyUse stores the sign count of a passkey after a successful
+// assertion. Passkeys whose counter did not grow are rejected: two copies of
+// the credential may exist.
+func recordPasskeyUse(ctx context.Context, c *fiber.Ctx, userID string, credential *webauthn.Credential) error {
+	updated := int64(0)
+	if !credential.Authenticator.CloneWarning {
+		var err error
+		updated, err = queries.UpdateWebAuthnCredentialUsage(ctx,
+			credential.ID,
+			pgtype.Int8{Int64: int64(credential.Authenticator.SignCount), Valid: true},
+			pgtype.Bool{Bool: credential.Flags.BackupState, Valid: true},
+			pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+		)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to update passkey")
+		}
+	}
+
+	if updated == 0 {
+		recordAuthEvent(ctx, c, userID, "PASSKEY_CLONE_WARNING", fiber.Map{"sign_count": credential.Authenticator.SignCount})
+		return fiber.NewError(fiber.StatusUnauthorized, "Passkey rejected: its signature counter did not increase")
+	}
+	return nil
+}
+
+// @Summary Begin passkey registration
+// @Description Start registering a passkey for the current user; requires a sign-in within STEP_UP_MAX_AGE. Pass options to navigator.credentials.create() and send the result to /auth/webauthn/register/finish.
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} WebAuthnCeremonyResponse
+// @Router /auth/webauthn/register/begin [post]
+func beginPasskeyRegistrationHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	ctx := context.Background()
+
+	user, err := loadWebAuthnUser(ctx, payload.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	// Discoverable credentials allow passwordless login; an authenticator
+	// that cannot store one still works as a second factor
+	creation, session, err := webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		}),
+	)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start passkey registration")
+	}
+	handle, err := saveWebAuthnSession(ctx, ceremonyRegistration, user.id, session)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start passkey registration")
+	}
+
+	return c.JSON(WebAuthnCeremonyResponse{SessionID: handle, Options: creation})
+}
+
+// @Summary Finish passkey registration
+// @Description Store the passkey created by the browser
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyRegisterRequest true "Passkey Register Request"
+// @Success 201 {object} PasskeyResponse
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/webauthn/register/finish [post]
+func finishPasskeyRegistrationHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	var req PasskeyRegisterRequest
+	if err := c.BodyParser(&req); err != nil || req.SessionID == "" || len(req.Credential) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len([]rune(name)) > passkeyNameMaxLength {
+		return fiber.NewError(fiber.StatusBadRequest, "Passkey name is too long")
+	}
+
+	ctx := context.Background()
+	userID, session, err := consumeWebAuthnSession(ctx, ceremonyRegistration, req.SessionID)
+	if err != nil || userID != payload.UserID {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired registration session")
+	}
+	user, err := loadWebAuthnUser(ctx, userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid credential")
+	}
+	credential, err := webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Passkey registration failed")
+	}
+
+	id, err := utils.GenerateID()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate ID")
+	}
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	row, err := queries.CreateWebAuthnCredential(ctx,
+		pgtype.Text{String: id, Valid: true},
+		pgtype.Text{String: user.id, Valid: true},
+		credential.ID,
+		credential.PublicKey,
+		pgtype.Text{String: credential.AttestationType, Valid: true},
+		transports,
+		credential.Authenticator.AAGUID,
+		pgtype.Int8{Int64: int64(credential.Authenticator.SignCount), Valid: true},
+		pgtype.Bool{Bool: credential.Flags.BackupEligible, Valid: true},
+		pgtype.Bool{Bool: credential.Flags.BackupState, Valid: true},
+		pgtype.Text{String: name, Valid: true},
+	)
+	if err != nil {
+		return fiber.NewError(fiber.StatusConflict, "Passkey is already registered")
+	}
+	recordAuthEvent(ctx, c, user.id, "PASSKEY_ADDED", fiber.Map{"passkey_id": row.ID, "name": row.Name})
+
+	return c.Status(fiber.StatusCreated).JSON(newPasskeyResponse(row))
+}
+
+// @Summary Begin passkey login
+// @Description Start a passkey assertion. Without mfa_token the passkey signs the user in on its own (user verification is required); with the mfa_token of a login it is the second factor.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyLoginBeginRequest false "Passkey Login Request"
+// @Success 200 {object} WebAuthnCeremonyResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /auth/webauthn/login/begin [post]
+func beginPasskeyLoginHandler(c *fiber.Ctx) error {
+	var req PasskeyLoginBeginRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+	ctx := context.Background()
+
+	if req.MFAToken == "" {
+		assertion, session, err := webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to start passkey login")
+		}
+		handle, err := saveWebAuthnSession(ctx, ceremonyLogin, "", session)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to start passkey login")
+		}
+		return c.JSON(WebAuthnCeremonyResponse{SessionID: handle, Options: assertion})
+	}
+
+	account, err := loadMFAChallenge(ctx, c, req.MFAToken)
+	if err != nil {
+		return err
+	}
+	user, err := loadWebAuthnUser(ctx, account.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+	if len(user.credentials) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "No passkeys registered for this account")
+	}
+
+	assertion, session, err := webAuthn.BeginLogin(user, webauthn.WithUserVerification(protocol.VerificationPreferred))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start passkey login")
+	}
+	handle, err := saveWebAuthnSession(ctx, ceremonyMFA, user.id, session)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start passkey login")
+	}
+	return c.JSON(WebAuthnCeremonyResponse{SessionID: handle, Options: assertion})
+}
+
+// @Summary Finish passkey login
+// @Description Verify the assertion of a passkey and sign the user in. Pass the same mfa_token as to /auth/webauthn/login/begin when the passkey is the second factor.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyLoginFinishRequest true "Passkey Login Request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /auth/webauthn/login/finish [post]
+func finishPasskeyLoginHandler(c *fiber.Ctx) error {
+	var req PasskeyLoginFinishRequest
+	if err := c.BodyParser(&req); err != nil || req.SessionID == "" || len(req.Credential) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid credential")
+	}
+
+	ctx := context.Background()
+	if req.MFAToken != "" {
+		return finishPasskeyMFA(ctx, c, req, parsed)
+	}
+
+	_, session, err := consumeWebAuthnSession(ctx, ceremonyLogin, req.SessionID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired login session")
+	}
+	found, credential, err := webAuthn.ValidatePasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		return loadWebAuthnUser(ctx, string(userHandle))
+	}, *session, parsed)
+	if err != nil {
+		recordAuthEvent(ctx, c, "", "FAILED_LOGIN", fiber.Map{"method": "passkey", "reason": "invalid_passkey"})
+		return fiber.NewError(fiber.StatusUnauthorized, "Passkey verification failed")
+	}
+
+	user, err := queries.GetUserByID(ctx, pgtype.Text{String: found.(*webAuthnUser).id, Valid: true})
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Passkey verification failed")
+	}
+	if err := checkAccountStatus(user.Status, user.StatusExpiresAt); err != nil {
+		recordAuthEvent(ctx, c, user.ID, "FAILED_LOGIN", fiber.Map{"method": "passkey", "reason": "account_" + user.Status})
+		return err
+	}
+	if cfg.RequireVerifiedEmail && !user.EmailVerified.Valid {
+		recordAuthEvent(ctx, c, user.ID, "FAILED_LOGIN", fiber.Map{"method": "passkey", "reason": "email_not_verified"})
+		return fiber.NewError(fiber.StatusForbidden, "Email address is not verified")
+	}
+	if err := recordPasskeyUse(ctx, c, user.ID, credential); err != nil {
+		return err
+	}
+
+	// A passkey verified with PIN or biometrics is two factors by itself,
+	// so there is no further MFA challenge
+	tokens, err := issueSession(ctx, queries, user.ID, "", newAuthentication(auth.AMRHardwareKey, auth.AMRMultiFactor))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create access token")
+	}
+	recordAuthEvent(ctx, c, user.ID, "LOGIN", fiber.Map{"method": "passkey"})
+
+	return c.JSON(sessionResponse(tokens, newSessionUser(user)))
+}
+
+// finishPasskeyMFA completes an MFA challenge with a passkey assertion
+func finishPasskeyMFA(ctx context.Context, c *fiber.Ctx, req PasskeyLoginFinishRequest, parsed *protocol.ParsedCredentialAssertionData) error {
+	account, err := loadMFAChallenge(ctx, c, req.MFAToken)
+	if err != nil {
+		return err
+	}
+	userID, session, err := consumeWebAuthnSession(ctx, ceremonyMFA, req.SessionID)
+	if err != nil || userID != account.ID {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired login session")
+	}
+	user, err := loadWebAuthnUser(ctx, userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+
+	credential, err := webAuthn.ValidateLogin(user, *session, parsed)
+	if err != nil {
+		recordLoginFailure(ctx, c, account.Email.String, account.ID, "invalid_"+factorWebAuthn)
+		return fiber.NewError(fiber.StatusUnauthorized, "Passkey verification failed")
+	}
+	if err := recordPasskeyUse(ctx, c, account.ID, credential); err != nil {
+		return err
+	}
+
+	return finishMFALogin(ctx, c, req.MFAToken, account, factorWebAuthn)
+}
+
+// @Summary List passkeys
+// @Description List the passkeys registered by the current user
+// @Tags User
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} PasskeyResponse
+// @Router /users/me/passkeys [get]
+func listPasskeysHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	rows, err := queries.ListWebAuthnCredentials(context.Background(), pgtype.Text{String: payload.UserID, Valid: true})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to list passkeys")
+	}
+
+	passkeys := make([]PasskeyResponse, 0, len(rows))
+	for _, row := range rows {
+		passkeys = append(passkeys, newPasskeyResponse(row))
+	}
+	return c.JSON(passkeys)
+}
+
+// @Summary Remove passkey
+// @Description Remove one of the current user's passkeys
+// @Tags User
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Passkey ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]interface{}
+// @Router /users/me/passkeys/{id} [delete]
+func deletePasskeyHandler(c *fiber.Ctx) error {
+	payload := c.Locals("payload").(*auth.Payload)
+	ctx := context.Background()
+
+	deleted, err := queries.DeleteWebAuthnCredential(ctx,
+		pgtype.Text{String: c.Params("id"), Valid: true},
+		pgtype.Text{String: payload.UserID, Valid: true},
+	)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to remove passkey")
+	}
+	if deleted == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "Passkey not found")
+	}
+	recordAuthEvent(ctx, c, payload.UserID, "PASSKEY_REMOVED", fiber.Map{"passkey_id": c.Params("id")})
+
+	return c.JSON(fiber.Map{"status": "passkey_removed"})
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+)
+
+// softAuthenticator is a platform authenticator in software: an ECDSA P-256
+// key pair with "none" attestation and user verification
+type softAuthenticator struct {
+	key        *ecdsa.PrivateKey
+	id         []byte
+	userHandle []byte
+	// signCount is reported by the next assertion
+	signCount uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{key: key, id: id}
+}
+
+func (a *softAuthenticator) authenticatorData(rpID string, flags protocol.AuthenticatorFlags) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], byte(flags))
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func clientDataJSON(t *testing.T, ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+	data, err := json.Marshal(protocol.CollectedClientData{Type: ceremony, Challenge: challenge.String(), Origin: testOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// create answers navigator.credentials.create()
+func (a *softAuthenticator) create(t *testing.T, options protocol.CredentialCreation) json.RawMessage {
+	t.Helper()
+	userHandle, err := base64.RawURLEncoding.DecodeString(options.Response.User.ID.(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authData := a.authenticatorData(options.Response.RelyingParty.ID, protocol.FlagUserPresent|protocol.FlagUserVerified|protocol.FlagAttestedCredentialData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.id)))
+	authData = append(authData, a.id...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": authData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credentialJSON(t, a.id, map[string]any{
+		"clientDataJSON":    protocol.URLEncodedBase64(clientDataJSON(t, protocol.CreateCeremony, options.Response.Challenge)),
+		"attestationObject": protocol.URLEncodedBase64(attestation),
+	})
+}
+
+// get answers navigator.credentials.get() with the given user handle
+func (a *softAuthenticator) get(t *testing.T, options protocol.CredentialAssertion, userHandle []byte) json.RawMessage {
+	t.Helper()
+	authData := a.authenticatorData(options.Response.RelyingPartyID, protocol.FlagUserPresent|protocol.FlagUserVerified)
+	clientData := clientDataJSON(t, protocol.AssertCeremony, options.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(bytes.Clone(authData), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credentialJSON(t, a.id, map[string]any{
+		"clientDataJSON":    protocol.URLEncodedBase64(clientData),
+		"authenticatorData": protocol.URLEncodedBase64(authData),
+		"signature":         protocol.URLEncodedBase64(signature),
+		"userHandle":        protocol.URLEncodedBase64(userHandle),
+	})
+}
+
+func credentialJSON(t *testing.T, id []byte, response map[string]any) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"id":       protocol.URLEncodedBase64(id).String(),
+		"rawId":    protocol.URLEncodedBase64(id),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// passkeyServer serves the passkey routes on top of testServer and keeps
+// credentials and ceremonies in memory
+type passkeyServer struct {
+	*testServer
+	credentials []db.WebauthnCredential
+	ceremonies  map[string]db.ConsumeWebAuthnSessionRow
+}
+
+func newPasskeyServer(t *testing.T) *passkeyServer {
+	s := &passkeyServer{testServer: newTestServer(t), ceremonies: map[string]db.ConsumeWebAuthnSessionRow{}}
+
+	s.db.Handle("CreateWebAuthnSession", func(args ...any) (any, error) {
+		s.ceremonies[args[0].(pgtype.Text).String+"/"+args[2].(pgtype.Text).String] = db.ConsumeWebAuthnSessionRow{
+			UserID: args[1].(pgtype.Text), Data: args[3].([]byte), ExpiresAt: args[4].(pgtype.Timestamp),
+		}
+		return nil, nil
+	})
+	s.db.Handle("ConsumeWebAuthnSession", func(args ...any) (any, error) {
+		key := args[0].(pgtype.Text).String + "/" + args[1].(pgtype.Text).String
+		row, ok := s.ceremonies[key]
+		if !ok {
+			return nil, nil
+		}
+		delete(s.ceremonies, key)
+		return row, nil
+	})
+	s.db.Handle("ListWebAuthnCredentials", func(args ...any) (any, error) {
+		var rows []db.WebauthnCredential
+		for _, cred := range s.credentials {
+			if cred.UserID == args[0].(pgtype.Text).String {
+				rows = append(rows, cred)
+			}
+		}
+		return rows, nil
+	})
+	s.db.Handle("CountWebAuthnCredentials", func(args ...any) (any, error) {
+		n := int64(0)
+		for _, cred := range s.credentials {
+			if cred.UserID == args[0].(pgtype.Text).String {
+				n++
+			}
+		}
+		return n, nil
+	})
+	s.db.Handle("CreateWebAuthnCredential", func(args ...any) (any, error) {
+		cred := db.WebauthnCredential{
+			ID:              args[0].(pgtype.Text).String,
+			UserID:          args[1].(pgtype.Text).String,
+			CredentialID:    args[2].([]byte),
+			PublicKey:       args[3].([]byte),
+			AttestationType: args[4].(pgtype.Text).String,
+			Transports:      args[5].([]string),
+			Aaguid:          args[6].([]byte),
+			SignCount:       args[7].(pgtype.Int8).Int64,
+			BackupEligible:  args[8].(pgtype.Bool).Bool,
+			BackupState:     args[9].(pgtype.Bool).Bool,
+			Name:            args[10].(pgtype.Text).String,
+			CreatedAt:       pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+		}
+		s.credentials = append(s.credentials, cred)
+		return cred, nil
+	})
+	// Same condition as the WHERE clause of the query
+	s.db.Handle("UpdateWebAuthnCredentialUsage", func(args ...any) (any, error) {
+		signCount := args[1].(pgtype.Int8).Int64
+		for i, cred := range s.credentials {
+			if bytes.Equal(cred.CredentialID, args[0].([]byte)) && (cred.SignCount < signCount || (cred.SignCount == 0 && signCount == 0)) {
+				s.credentials[i].SignCount = signCount
+				return int64(1), nil
+			}
+		}
+		return int64(0), nil
+	})
+
+	s.app.Post("/register/begin", authMiddleware, beginPasskeyRegistrationHandler)
+	s.app.Post("/register/finish", authMiddleware, finishPasskeyRegistrationHandler)
+	s.app.Post("/login/begin", beginPasskeyLoginHandler)
+	s.app.Post("/login/finish", finishPasskeyLoginHandler)
+	return s
+}
+
+type ceremony[T any] struct {
+	SessionID string `json:"session_id"`
+	Options   T      `json:"options"`
+}
+
+// register adds a passkey to the account signed in with accessToken
+func (s *passkeyServer) register(t *testing.T, accessToken string, a *softAuthenticator) int {
+	t.Helper()
+	var begin ceremony[protocol.CredentialCreation]
+	if status := s.call(t, "/register/begin", accessToken, nil, &begin); status != fiber.StatusOK {
+		return status
+	}
+	return s.call(t, "/register/finish", accessToken, PasskeyRegisterRequest{
+		SessionID:  begin.SessionID,
+		Credential: a.create(t, begin.Options),
+	}, nil)
+}
+
+// login signs in with a passkey alone, presenting userHandle
+func (s *passkeyServer) login(t *testing.T, a *softAuthenticator, userHandle []byte) int {
+	t.Helper()
+	var begin ceremony[protocol.CredentialAssertion]
+	if status := s.call(t, "/login/begin", "", nil, &begin); status != fiber.StatusOK {
+		t.Fatalf("login begin: %d", status)
+	}
+	var session map[string]any
+	status := s.call(t, "/login/finish", "", PasskeyLoginFinishRequest{
+		SessionID:  begin.SessionID,
+		Credential: a.get(t, begin.Options, userHandle),
+	}, &session)
+	if status == fiber.StatusOK && session["access_token"] == "" {
+		t.Fatal("login returned no access token")
+	}
+	return status
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	s := newPasskeyServer(t)
+	s.addUser("alice", "alice@example.com")
+	a := newSoftAuthenticator(t)
+
+	if status := s.register(t, s.signIn(t, "alice").AccessToken, a); status != fiber.StatusCreated {
+		t.Fatalf("register: got %d, want 201", status)
+	}
+	if len(s.credentials) != 1 || s.credentials[0].UserID != "alice" || !bytes.Equal(s.credentials[0].CredentialID, a.id) {
+		t.Fatalf("stored credentials: %+v", s.credentials)
+	}
+	if string(a.userHandle) != "alice" {
+		t.Fatalf("user handle is %q, want the user ID", a.userHandle)
+	}
+
+	for _, count := range []uint32{1, 5} {
+		a.signCount = count
+		if status := s.login(t, a, a.userHandle); status != fiber.StatusOK {
+			t.Fatalf("login with sign count %d: got %d, want 200", count, status)
+		}
+	}
+	if s.credentials[0].SignCount != 5 {
+		t.Errorf("stored sign count is %d, want 5", s.credentials[0].SignCount)
+	}
+	if !s.recorded("PASSKEY_ADDED") || !s.recorded("LOGIN") {
+		t.Errorf("audit events: %v", s.events)
+	}
+}
+
+func TestPasskeyLoginSignCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []uint32
+		// want is the status of the last login
+		want int
+	}{
+		{"increasing", []uint32{1, 2}, fiber.StatusOK},
+		{"repeated", []uint32{3, 3}, fiber.StatusUnauthorized},
+		{"decreasing", []uint32{3, 2}, fiber.StatusUnauthorized},
+		{"reset to zero", []uint32{3, 0}, fiber.StatusUnauthorized},
+		// Authenticators without a counter always report 0
+		{"zero after zero", []uint32{0, 0, 0}, fiber.StatusOK},
+		{"starts counting", []uint32{0, 1}, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPasskeyServer(t)
+			s.addUser("alice", "alice@example.com")
+			a := newSoftAuthenticator(t)
+			if status := s.register(t, s.signIn(t, "alice").AccessToken, a); status != fiber.StatusCreated {
+				t.Fatalf("register: got %d", status)
+			}
+
+			var status int
+			for i, count := range tt.counts {
+				a.signCount = count
+				status = s.login(t, a, a.userHandle)
+				if i < len(tt.counts)-1 && status != fiber.StatusOK {
+					t.Fatalf("login %d: got %d, want 200", i+1, status)
+				}
+			}
+			if status != tt.want {
+				t.Errorf("got %d, want %d", status, tt.want)
+			}
+			if cloned := s.recorded("PASSKEY_CLONE_WARNING"); cloned != (tt.want != fiber.StatusOK) {
+				t.Errorf("PASSKEY_CLONE_WARNING recorded: %v", cloned)
+			}
+		})
+	}
+}
+
+func TestPasskeyLoginUserHandle(t *testing.T) {
+	s := newPasskeyServer(t)
+	s.addUser("alice", "alice@example.com")
+	s.addUser("mallory", "mallory@example.com")
+	alice := newSoftAuthenticator(t)
+	mallory := newSoftAuthenticator(t)
+	if status := s.register(t, s.signIn(t, "alice").AccessToken, alice); status != fiber.StatusCreated {
+		t.Fatalf("register alice: got %d", status)
+	}
+	if status := s.register(t, s.signIn(t, "mallory").AccessToken, mallory); status != fiber.StatusCreated {
+		t.Fatalf("register mallory: got %d", status)
+	}
+
+	// Mallory's passkey claiming to be Alice's
+	mallory.signCount = 1
+	if status := s.login(t, mallory, alice.userHandle); status != fiber.StatusUnauthorized {
+		t.Errorf("other user's handle: got %d, want 401", status)
+	}
+	if status := s.login(t, mallory, []byte("nobody")); status != fiber.StatusUnauthorized {
+		t.Errorf("unknown handle: got %d, want 401", status)
+	}
+	if s.credentials[1].SignCount != 0 {
+		t.Errorf("rejected logins changed the sign count to %d", s.credentials[1].SignCount)
+	}
+	if status := s.login(t, mallory, mallory.userHandle); status != fiber.StatusOK {
+		t.Errorf("own handle: got %d, want 200", status)
+	}
+}
+
+func TestPasskeyDeletedUser(t *testing.T) {
+	s := newPasskeyServer(t)
+	s.addUser("alice", "alice@example.com")
+	a := newSoftAuthenticator(t)
+	tokens := s.signIn(t, "alice")
+	if status := s.register(t, tokens.AccessToken, a); status != fiber.StatusCreated {
+		t.Fatalf("register: got %d", status)
+	}
+
+	s.deleteUser("alice")
+	a.signCount = 1
+	if status := s.login(t, a, a.userHandle); status != fiber.StatusUnauthorized {
+		t.Errorf("login: got %d, want 401", status)
+	}
+	if status := s.register(t, tokens.AccessToken, newSoftAuthenticator(t)); status != fiber.StatusNotFound {
+		t.Errorf("register: got %d, want 404", status)
+	}
+}
@@ -0,0 +1,56 @@
+-- name: LockAuditChain :exec
+LOCK TABLE authenserver_service.audit_chain_head IN EXCLUSIVE MODE;
+
+-- name: GetAuditChainHead :one
+SELECT seq, entry_hash
+FROM authenserver_service.audit_chain_head
+WHERE id = 1;
+
+-- name: GetAuditChainHeadForUpdate :one
+SELECT seq, entry_hash
+FROM authenserver_service.audit_chain_head
+WHERE id = 1
+FOR UPDATE;
+
+-- name: SetAuditChainHead :exec
+INSERT INTO authenserver_service.audit_chain_head (id, seq, entry_hash, updated_at)
+VALUES (1, $1, $2, NOW())
+ON CONFLICT (id) DO UPDATE SET seq = $1, entry_hash = $2, updated_at = NOW();
+
+-- name: SealAuthLog :exec
+UPDATE authenserver_service.auth_logs
+SET seq = $2, prev_hash = $3, entry_hash = $4
+WHERE id = $1;
+
+-- name: ListUnchainedAuthLogs :many
+SELECT id, user_id, action, ip_address, user_agent, timestamp, metadata
+FROM authenserver_service.auth_logs
+WHERE seq IS NULL
+ORDER BY timestamp, id;
+
+-- name: CountUnchainedAuthLogs :one
+SELECT COUNT(*) FROM authenserver_service.auth_logs
+WHERE seq IS NULL;
+
+-- name: ListAuthLogChain :many
+SELECT id, user_id, action, ip_address, user_agent, timestamp, metadata, seq, prev_hash, entry_hash
+FROM authenserver_service.auth_logs
+WHERE seq > $1
+ORDER BY seq
+LIMIT $2;
+
+-- name: CreateAuditCheckpoint :exec
+INSERT INTO authenserver_service.audit_checkpoints (seq, entry_hash, key_id, signature, created_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (seq) DO NOTHING;
+
+-- name: GetLatestAuditCheckpoint :one
+SELECT seq, entry_hash, key_id, signature, created_at
+FROM authenserver_service.audit_checkpoints
+ORDER BY seq DESC
+LIMIT 1;
+
+-- name: ListAuditCheckpoints :many
+SELECT seq, entry_hash, key_id, signature, created_at
+FROM authenserver_service.audit_checkpoints
+ORDER BY seq;
@@ -1,10 +1,10 @@
 -- name: CreateAuthLog :one
 INSERT INTO authenserver_service.auth_logs (
-    id, user_id, action, ip_address, user_agent, timestamp
+    id, user_id, action, ip_address, user_agent, timestamp, metadata
 ) VALUES (
-    $1, $2, $3, $4, $5, NOW()
+    $1, $2, $3, $4, $5, NOW(), $6
 )
-RETURNING id, user_id, action, ip_address, user_agent, timestamp;
+RETURNING id, user_id, action, ip_address, user_agent, timestamp, metadata;
 
 -- name: GetAuthLogsByUser :many
 SELECT id, user_id, action, ip_address, user_agent, timestamp
@@ -18,3 +18,22 @@ SELECT id, user_id, action, ip_address, user_agent, timestamp
 FROM authenserver_service.auth_logs
 ORDER BY timestamp DESC
 LIMIT $1 OFFSET $2;
+
+-- name: ListAuditLogs :many
+-- Every filter is optional (NULL). Pages are ordered newest first and
+-- continue after the (timestamp, id) of the last row of the previous page.
+-- Entries are hash-chained and cannot be cleaned up, so stored addresses that
+-- are not IPs are skipped rather than cast (pg_input_is_valid needs
+-- PostgreSQL 16).
+SELECT id, user_id, action, ip_address, user_agent, timestamp, metadata
+FROM authenserver_service.auth_logs
+WHERE ($1::text IS NULL OR user_id = $1)
+  AND ($2::text[] IS NULL OR action = ANY($2::text[]))
+  AND ($3::text IS NULL OR CASE WHEN pg_input_is_valid(ip_address, 'inet')
+    THEN ip_address::inet <<= $3::text::cidr END)
+  AND ($4::timestamp IS NULL OR timestamp >= $4)
+  AND ($5::timestamp IS NULL OR timestamp < $5)
+  AND ($6::jsonb IS NULL OR metadata @> $6::jsonb)
+  AND ($7::timestamp IS NULL OR (timestamp, id) < ($7::timestamp, $8::text))
+ORDER BY timestamp DESC, id DESC
+LIMIT $9;
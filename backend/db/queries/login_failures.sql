@@ -0,0 +1,31 @@
+-- name: RecordLoginFailure :one
+INSERT INTO authenserver_service.login_failures (
+    attempt_key, failures, last_failure_at
+) VALUES (
+    $1, 1, $2
+)
+ON CONFLICT (attempt_key) DO UPDATE SET
+    failures = CASE
+        WHEN login_failures.last_failure_at < $3 THEN 1
+        ELSE login_failures.failures + 1
+    END,
+    last_failure_at = EXCLUDED.last_failure_at
+RETURNING failures;
+
+-- name: BlockLoginAttempts :exec
+UPDATE authenserver_service.login_failures
+SET blocked_until = $2
+WHERE attempt_key = $1;
+
+-- name: GetLoginBlockedUntil :one
+SELECT MAX(blocked_until)::timestamp AS blocked_until
+FROM authenserver_service.login_failures
+WHERE attempt_key IN ($1, $2) AND blocked_until > $3;
+
+-- name: ClearLoginFailures :exec
+DELETE FROM authenserver_service.login_failures
+WHERE attempt_key = $1;
+
+-- name: DeleteStaleLoginFailures :exec
+DELETE FROM authenserver_service.login_failures
+WHERE last_failure_at < $1 AND (blocked_until IS NULL OR blocked_until < $2);
@@ -0,0 +1,51 @@
+-- name: StartUserMFA :one
+INSERT INTO authenserver_service.user_mfa (
+    user_id, totp_secret
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (user_id) DO UPDATE SET
+    totp_secret = EXCLUDED.totp_secret,
+    last_used_step = 0,
+    created_at = NOW()
+WHERE user_mfa.confirmed_at IS NULL
+RETURNING user_id, totp_secret, confirmed_at, last_used_step, created_at;
+
+-- name: GetUserMFA :one
+SELECT user_id, totp_secret, confirmed_at, last_used_step, created_at
+FROM authenserver_service.user_mfa
+WHERE user_id = $1;
+
+-- name: ConfirmUserMFA :execrows
+UPDATE authenserver_service.user_mfa
+SET confirmed_at = $2, last_used_step = $3
+WHERE user_id = $1 AND confirmed_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE authenserver_service.user_mfa
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserMFA :exec
+DELETE FROM authenserver_service.user_mfa
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO authenserver_service.mfa_recovery_codes (
+    user_id, code_hash
+) VALUES (
+    $1, $2
+);
+
+-- name: UseRecoveryCode :execrows
+UPDATE authenserver_service.mfa_recovery_codes
+SET used_at = $3
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM authenserver_service.mfa_recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM authenserver_service.mfa_recovery_codes
+WHERE user_id = $1;
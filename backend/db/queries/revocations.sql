@@ -0,0 +1,25 @@
+-- name: RevokeToken :exec
+INSERT INTO authenserver_service.revoked_tokens (
+    token_id, user_id, expires_at
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (token_id) DO NOTHING;
+
+-- name: IsTokenRevoked :one
+SELECT (
+    EXISTS (
+        SELECT 1 FROM authenserver_service.revoked_tokens
+        WHERE token_id = $1
+    ) OR EXISTS (
+        SELECT 1 FROM authenserver_service.users
+        WHERE id = $2 AND (
+            tokens_valid_after > $3
+            OR (status <> 'active' AND (status_expires_at IS NULL OR status_expires_at > $4))
+        )
+    )
+) AS revoked;
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM authenserver_service.revoked_tokens
+WHERE expires_at < NOW();
@@ -0,0 +1,21 @@
+-- name: CreateServiceClient :one
+INSERT INTO authenserver_service.service_clients (
+    id, name, secret_hash
+) VALUES (
+    $1, $2, $3
+)
+RETURNING id, name, created_at;
+
+-- name: GetServiceClient :one
+SELECT id, name, secret_hash, created_at
+FROM authenserver_service.service_clients
+WHERE id = $1 LIMIT 1;
+
+-- name: ListServiceClients :many
+SELECT id, name, created_at
+FROM authenserver_service.service_clients
+ORDER BY name;
+
+-- name: DeleteServiceClient :exec
+DELETE FROM authenserver_service.service_clients
+WHERE id = $1;
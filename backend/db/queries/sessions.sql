@@ -23,3 +23,28 @@ WHERE expires < NOW();
 -- name: DeleteUserSessions :exec
 DELETE FROM authenserver_service.sessions
 WHERE user_id = $1;
+
+-- name: CreateRefreshSession :one
+INSERT INTO authenserver_service.sessions (
+    id, session_token, user_id, expires, family_id, auth_time, amr
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING id, session_token, user_id, expires, family_id;
+
+-- name: GetRefreshSessionForUpdate :one
+SELECT id, session_token, user_id, expires, family_id, rotated_at, revoked_at, auth_time, amr
+FROM authenserver_service.sessions
+WHERE session_token = $1
+LIMIT 1
+FOR UPDATE;
+
+-- name: MarkSessionRotated :exec
+UPDATE authenserver_service.sessions
+SET rotated_at = NOW()
+WHERE id = $1;
+
+-- name: RevokeSessionFamily :exec
+UPDATE authenserver_service.sessions
+SET revoked_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,22 @@
+-- name: ListTokenKeys :many
+SELECT id, purpose, secret, activates_at, retires_at, created_at
+FROM authenserver_service.token_keys
+WHERE retires_at IS NULL OR retires_at > NOW()
+ORDER BY activates_at DESC;
+
+-- name: CreateTokenKey :exec
+INSERT INTO authenserver_service.token_keys (
+    id, purpose, secret, activates_at
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: RetireTokenKeys :exec
+UPDATE authenserver_service.token_keys
+SET retires_at = $2
+WHERE purpose = $1 AND retires_at IS NULL;
+
+-- name: DeleteRetiredTokenKeys :exec
+DELETE FROM authenserver_service.token_keys
+WHERE retires_at < NOW();
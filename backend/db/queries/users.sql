@@ -1,12 +1,12 @@
 -- name: GetUserByID :one
-SELECT id, name, email, email_verified, image, password, created_at, updated_at
+SELECT id, name, email, email_verified, image, password, created_at, updated_at, must_change_password, deleted_at, status, status_reason, status_expires_at
 FROM authenserver_service.users
 WHERE id = $1 LIMIT 1;
 
 -- name: GetUserByEmail :one
-SELECT id, name, email, email_verified, image, password, created_at, updated_at
+SELECT id, name, email, email_verified, image, password, created_at, updated_at, must_change_password, status, status_reason, status_expires_at
 FROM authenserver_service.users
-WHERE email = $1 LIMIT 1;
+WHERE email = $1 AND deleted_at IS NULL LIMIT 1;
 
 -- name: CreateUser :one
 INSERT INTO authenserver_service.users (
@@ -29,7 +29,12 @@ RETURNING id, name, email, email_verified, image, password, created_at, updated_
 
 -- name: UpdateUserPassword :exec
 UPDATE authenserver_service.users
-SET password = $2, updated_at = NOW()
+SET password = $2, must_change_password = FALSE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetMustChangePassword :exec
+UPDATE authenserver_service.users
+SET must_change_password = $2, updated_at = NOW()
 WHERE id = $1;
 
 -- name: DeleteUser :exec
@@ -37,10 +42,43 @@ DELETE FROM authenserver_service.users
 WHERE id = $1;
 
 -- name: ListUsers :many
-SELECT id, name, email, email_verified, image, password, created_at, updated_at
+SELECT id, name, email, email_verified, image, password, created_at, updated_at, status, status_reason, status_expires_at
 FROM authenserver_service.users
+WHERE deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT $1 OFFSET $2;
 
 -- name: CountUsers :one
-SELECT COUNT(*) FROM authenserver_service.users;
+SELECT COUNT(*) FROM authenserver_service.users
+WHERE deleted_at IS NULL;
+
+-- name: RevokeUserTokens :exec
+UPDATE authenserver_service.users
+SET tokens_valid_after = $2
+WHERE id = $1;
+
+-- name: SoftDeleteUser :exec
+UPDATE authenserver_service.users
+SET deleted_at = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestoreUser :execrows
+UPDATE authenserver_service.users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at > $2;
+
+-- name: ListDeletedUsers :many
+SELECT id, name, email, deleted_at
+FROM authenserver_service.users
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+LIMIT $1 OFFSET $2;
+
+-- name: PurgeDeletedUsers :exec
+DELETE FROM authenserver_service.users
+WHERE deleted_at < $1;
+
+-- name: SetUserStatus :exec
+UPDATE authenserver_service.users
+SET status = $2, status_reason = $3, status_expires_at = $4, updated_at = NOW()
+WHERE id = $1;
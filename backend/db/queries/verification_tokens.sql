@@ -0,0 +1,29 @@
+-- name: CreateVerificationToken :exec
+INSERT INTO authenserver_service.verification_tokens (
+    identifier, token, expires
+) VALUES (
+    $1, $2, $3
+);
+
+-- name: ConsumeVerificationToken :one
+DELETE FROM authenserver_service.verification_tokens
+WHERE token = $1 AND split_part(identifier, ':', 1) = $2
+RETURNING identifier, expires;
+
+-- name: DeleteVerificationTokens :exec
+DELETE FROM authenserver_service.verification_tokens
+WHERE identifier = $1;
+
+-- name: DeleteExpiredVerificationTokens :exec
+DELETE FROM authenserver_service.verification_tokens
+WHERE expires < NOW();
+
+-- name: GetVerificationToken :one
+SELECT identifier, expires FROM authenserver_service.verification_tokens
+WHERE token = $1 AND split_part(identifier, ':', 1) = $2;
+
+-- name: AddVerificationAttempt :one
+UPDATE authenserver_service.verification_tokens
+SET attempts = attempts + 1
+WHERE identifier = $1
+RETURNING attempts;
@@ -0,0 +1,51 @@
+-- name: CreateWebAuthnCredential :one
+INSERT INTO authenserver_service.webauthn_credentials (
+    id, user_id, credential_id, public_key, attestation_type, transports,
+    aaguid, sign_count, backup_eligible, backup_state, name
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+)
+RETURNING id, user_id, credential_id, public_key, attestation_type, transports, aaguid, sign_count, backup_eligible, backup_state, name, created_at, last_used_at;
+
+-- name: ListWebAuthnCredentials :many
+SELECT id, user_id, credential_id, public_key, attestation_type, transports, aaguid, sign_count, backup_eligible, backup_state, name, created_at, last_used_at
+FROM authenserver_service.webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: CountWebAuthnCredentials :one
+SELECT COUNT(*) FROM authenserver_service.webauthn_credentials
+WHERE user_id = $1;
+
+-- name: UpdateWebAuthnCredentialUsage :execrows
+-- The sign count has to grow with every use. Authenticators that do not
+-- keep one (most synced passkeys) always report 0, so 0 after 0 is accepted;
+-- a count that does not grow otherwise hints at a cloned authenticator and
+-- updates no row.
+UPDATE authenserver_service.webauthn_credentials
+SET sign_count = $2, backup_state = $3, last_used_at = $4
+WHERE credential_id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0));
+
+-- name: DeleteWebAuthnCredential :execrows
+DELETE FROM authenserver_service.webauthn_credentials
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteUserWebAuthnCredentials :exec
+DELETE FROM authenserver_service.webauthn_credentials
+WHERE user_id = $1;
+
+-- name: CreateWebAuthnSession :exec
+INSERT INTO authenserver_service.webauthn_sessions (
+    id, user_id, ceremony, data, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5
+);
+
+-- name: ConsumeWebAuthnSession :one
+DELETE FROM authenserver_service.webauthn_sessions
+WHERE id = $1 AND ceremony = $2
+RETURNING user_id, data, expires_at;
+
+-- name: DeleteExpiredWebAuthnSessions :exec
+DELETE FROM authenserver_service.webauthn_sessions
+WHERE expires_at < $1;
@@ -0,0 +1,11 @@
+-- Refresh token rotation
+SET search_path TO authenserver_service;
+
+-- Every refresh token belongs to a family started at login. Rotated tokens
+-- are kept (with rotated_at set) until they expire so that a replayed token
+-- can be detected and the whole family revoked.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS family_id VARCHAR(255);
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_family_id ON sessions(family_id);
@@ -0,0 +1,17 @@
+-- Access token revocation
+SET search_path TO authenserver_service;
+
+-- Individually revoked access tokens (logout). Rows can be purged once the
+-- token would have expired anyway.
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    token_id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+-- Every access token issued before this instant is rejected (logout-all,
+-- password change, offboarding).
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_valid_after TIMESTAMP;
@@ -0,0 +1,16 @@
+-- PASETO key rotation
+SET search_path TO authenserver_service;
+
+-- Token keys created by runtime rotation. The key material is sealed with
+-- PASETO_SECRET_KEY; the kid is written into every token footer. A key
+-- signs from activates_at until a newer key activates and verifies tokens
+-- until retires_at.
+CREATE TABLE IF NOT EXISTS token_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    secret TEXT NOT NULL,
+    activates_at TIMESTAMP NOT NULL,
+    retires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_keys_retires_at ON token_keys(retires_at);
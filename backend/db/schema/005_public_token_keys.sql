@@ -0,0 +1,8 @@
+-- Ed25519 (v2.public) token keys
+SET search_path TO authenserver_service;
+
+-- 'local' keys encrypt v2.local tokens, 'public' keys hold the Ed25519 seed
+-- that signs v2.public tokens. Each purpose rotates independently.
+ALTER TABLE token_keys ADD COLUMN IF NOT EXISTS purpose VARCHAR(20) NOT NULL DEFAULT 'local';
+
+CREATE INDEX IF NOT EXISTS idx_token_keys_purpose ON token_keys(purpose);
@@ -0,0 +1,9 @@
+-- Service clients (resource servers calling the introspection endpoint)
+SET search_path TO authenserver_service;
+
+CREATE TABLE IF NOT EXISTS service_clients (
+    id VARCHAR(255) PRIMARY KEY, -- client_id
+    name VARCHAR(255) NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL, -- SHA-256 of the generated client secret
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
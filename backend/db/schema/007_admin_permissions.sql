@@ -0,0 +1,16 @@
+-- Permissions guarding the token key and service client admin endpoints
+SET search_path TO authenserver_service;
+
+INSERT INTO permissions (slug, description) VALUES
+    ('key.read', 'List token signing keys'),
+    ('key.write', 'Rotate token signing keys'),
+    ('client.read', 'List service clients'),
+    ('client.write', 'Create and delete service clients')
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.slug IN ('key.read', 'key.write', 'client.read', 'client.write')
+ON CONFLICT DO NOTHING;
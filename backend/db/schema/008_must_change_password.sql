@@ -0,0 +1,4 @@
+-- Force a password change at next login, e.g. after an admin reset
+SET search_path TO authenserver_service;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT FALSE;
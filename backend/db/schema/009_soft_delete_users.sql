@@ -0,0 +1,6 @@
+-- Soft deletion of users, purged after the retention period
+SET search_path TO authenserver_service;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,19 @@
+-- Account status: active, suspended, locked or pending, with an optional expiry
+SET search_path TO authenserver_service;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_reason TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_expires_at TIMESTAMP;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.table_constraints
+        WHERE constraint_schema = 'authenserver_service'
+        AND table_name = 'users'
+        AND constraint_name = 'users_status_check'
+    ) THEN
+        ALTER TABLE users ADD CONSTRAINT users_status_check
+        CHECK (status IN ('active', 'suspended', 'locked', 'pending'));
+    END IF;
+END $$;
@@ -0,0 +1,10 @@
+-- Failed password logins per account (email) and per client IP, used for
+-- back-off and temporary lockout
+SET search_path TO authenserver_service;
+
+CREATE TABLE IF NOT EXISTS login_failures (
+    attempt_key VARCHAR(320) PRIMARY KEY, -- "email:<address>" or "ip:<address>"
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    blocked_until TIMESTAMP
+);
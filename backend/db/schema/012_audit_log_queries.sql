@@ -0,0 +1,18 @@
+-- Indexes for the audit log API (filters and keyset pagination) and the
+-- permission guarding it
+SET search_path TO authenserver_service;
+
+CREATE INDEX IF NOT EXISTS idx_auth_logs_timestamp_id ON auth_logs(timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_auth_logs_action ON auth_logs(action);
+CREATE INDEX IF NOT EXISTS idx_auth_logs_metadata ON auth_logs USING GIN (metadata jsonb_path_ops);
+
+INSERT INTO permissions (slug, description) VALUES
+    ('audit.read', 'Search and export the audit log')
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.slug = 'audit.read'
+ON CONFLICT DO NOTHING;
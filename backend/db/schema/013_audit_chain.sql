@@ -0,0 +1,29 @@
+-- Hash chain over auth_logs: every entry stores the hash of its predecessor
+-- and of its own content, and signed checkpoints pin the chain head so that
+-- rewriting history is detectable
+SET search_path TO authenserver_service;
+
+ALTER TABLE auth_logs ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER TABLE auth_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64);
+ALTER TABLE auth_logs ADD COLUMN IF NOT EXISTS entry_hash VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_auth_logs_seq ON auth_logs(seq);
+
+-- Chained entries must never change, so purging a user no longer nulls
+-- their ID in the audit log
+ALTER TABLE auth_logs DROP CONSTRAINT IF EXISTS auth_logs_user_id_fkey;
+
+-- Single row holding the newest chained entry; locking it serializes appends
+CREATE TABLE IF NOT EXISTS audit_chain_head (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    seq BIGINT NOT NULL,
+    entry_hash VARCHAR(64) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    seq BIGINT PRIMARY KEY,
+    entry_hash VARCHAR(64) NOT NULL,
+    key_id VARCHAR(16) NOT NULL,
+    signature TEXT NOT NULL, -- base64 Ed25519 signature
+    created_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,19 @@
+-- TOTP second factor and single-use recovery codes
+SET search_path TO authenserver_service;
+
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id VARCHAR(255) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    totp_secret TEXT NOT NULL, -- sealed with the master key
+    confirmed_at TIMESTAMP, -- NULL while enrollment is pending
+    last_used_step BIGINT NOT NULL DEFAULT 0, -- rejects replayed codes
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
@@ -0,0 +1,28 @@
+-- WebAuthn credentials (passkeys) and the state of pending ceremonies
+SET search_path TO authenserver_service;
+
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL, -- COSE encoded
+    attestation_type VARCHAR(32) NOT NULL,
+    transports TEXT[] NOT NULL DEFAULT '{}',
+    aaguid BYTEA,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+CREATE TABLE IF NOT EXISTS webauthn_sessions (
+    id VARCHAR(64) PRIMARY KEY, -- SHA-256 of the session handle given to the client
+    user_id VARCHAR(255) REFERENCES users(id) ON DELETE CASCADE, -- NULL for passwordless logins
+    ceremony VARCHAR(20) NOT NULL, -- registration, login or mfa
+    data JSONB NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,6 @@
+-- How and when the user of a refresh token family signed in, carried into
+-- every access token of the family as auth_time and amr
+SET search_path TO authenserver_service;
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS amr TEXT[];
@@ -0,0 +1,5 @@
+-- Failed guesses of short verification codes (email login), which are
+-- discarded after too many attempts
+SET search_path TO authenserver_service;
+
+ALTER TABLE verification_tokens ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Emails only need to be unique among users that are not deleted, so that a
+-- deleted user's address (e.g. the root user's) can be registered again
+-- while the user can still be restored
+SET search_path TO authenserver_service;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users(email) WHERE deleted_at IS NULL;
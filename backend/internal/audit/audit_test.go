@@ -0,0 +1,423 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+	"github.com/yourusername/skoservice-authenserver/internal/db/dbtest"
+)
+
+// fakeLog keeps auth_logs, the chain head and the checkpoints in memory and
+// answers the queries of Chain and Verify like the database would
+type fakeLog struct {
+	db          *dbtest.DB
+	rows        []db.ListAuthLogChainRow
+	head        *db.GetAuditChainHeadRow
+	checkpoints []db.AuditCheckpoint
+}
+
+func newFakeLog() *fakeLog {
+	l := &fakeLog{db: dbtest.New()}
+	l.db.Handle("LockAuditChain", func(args ...any) (any, error) { return nil, nil })
+	l.db.Handle("GetAuditChainHead", func(args ...any) (any, error) {
+		if l.head == nil {
+			return nil, nil
+		}
+		return *l.head, nil
+	})
+	l.db.Handle("GetAuditChainHeadForUpdate", func(args ...any) (any, error) {
+		if l.head == nil {
+			return nil, nil
+		}
+		return db.GetAuditChainHeadForUpdateRow(*l.head), nil
+	})
+	l.db.Handle("SetAuditChainHead", func(args ...any) (any, error) {
+		l.head = &db.GetAuditChainHeadRow{Seq: args[0].(pgtype.Int8).Int64, EntryHash: args[1].(pgtype.Text).String}
+		return nil, nil
+	})
+	l.db.Handle("CreateAuthLog", func(args ...any) (any, error) {
+		row := db.ListAuthLogChainRow{
+			ID:        args[0].(pgtype.Text).String,
+			UserID:    args[1].(pgtype.Text),
+			Action:    args[2].(pgtype.Text).String,
+			IpAddress: args[3].(pgtype.Text),
+			UserAgent: args[4].(pgtype.Text),
+			Timestamp: pgtype.Timestamp{Time: time.Now().UTC().Truncate(time.Microsecond), Valid: true},
+			Metadata:  args[5].([]byte),
+		}
+		l.rows = append(l.rows, row)
+		return db.CreateAuthLogRow{ID: row.ID, UserID: row.UserID, Action: row.Action, IpAddress: row.IpAddress,
+			UserAgent: row.UserAgent, Timestamp: row.Timestamp, Metadata: row.Metadata}, nil
+	})
+	l.db.Handle("SealAuthLog", func(args ...any) (any, error) {
+		i := l.index(args[0].(pgtype.Text).String)
+		l.rows[i].Seq = args[1].(pgtype.Int8)
+		l.rows[i].PrevHash = args[2].(pgtype.Text)
+		l.rows[i].EntryHash = args[3].(pgtype.Text)
+		return int64(1), nil
+	})
+	l.db.Handle("ListUnchainedAuthLogs", func(args ...any) (any, error) {
+		var rows []db.ListUnchainedAuthLogsRow
+		for _, row := range l.rows {
+			if !row.Seq.Valid {
+				rows = append(rows, db.ListUnchainedAuthLogsRow{ID: row.ID, UserID: row.UserID, Action: row.Action,
+					IpAddress: row.IpAddress, UserAgent: row.UserAgent, Timestamp: row.Timestamp, Metadata: row.Metadata})
+			}
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].Timestamp.Time.Before(rows[j].Timestamp.Time) })
+		return rows, nil
+	})
+	l.db.Handle("CountUnchainedAuthLogs", func(args ...any) (any, error) {
+		n := int64(0)
+		for _, row := range l.rows {
+			if !row.Seq.Valid {
+				n++
+			}
+		}
+		return n, nil
+	})
+	l.db.Handle("ListAuthLogChain", func(args ...any) (any, error) {
+		var rows []db.ListAuthLogChainRow
+		for _, row := range l.rows {
+			if row.Seq.Valid && row.Seq.Int64 > args[0].(pgtype.Int8).Int64 {
+				rows = append(rows, row)
+			}
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].Seq.Int64 < rows[j].Seq.Int64 })
+		return rows[:min(len(rows), int(args[1].(pgtype.Int8).Int64))], nil
+	})
+	l.db.Handle("CreateAuditCheckpoint", func(args ...any) (any, error) {
+		l.checkpoints = append(l.checkpoints, db.AuditCheckpoint{
+			Seq:       args[0].(pgtype.Int8).Int64,
+			EntryHash: args[1].(pgtype.Text).String,
+			KeyID:     args[2].(pgtype.Text).String,
+			Signature: args[3].(pgtype.Text).String,
+			CreatedAt: args[4].(pgtype.Timestamp),
+		})
+		return int64(1), nil
+	})
+	l.db.Handle("GetLatestAuditCheckpoint", func(args ...any) (any, error) {
+		if len(l.checkpoints) == 0 {
+			return nil, nil
+		}
+		return slices.MaxFunc(l.checkpoints, func(a, b db.AuditCheckpoint) int { return int(a.Seq - b.Seq) }), nil
+	})
+	l.db.Handle("ListAuditCheckpoints", func(args ...any) (any, error) {
+		return l.checkpoints, nil
+	})
+	return l
+}
+
+// index returns the position of the entry with id
+func (l *fakeLog) index(id string) int {
+	return slices.IndexFunc(l.rows, func(row db.ListAuthLogChainRow) bool { return row.ID == id })
+}
+
+// bySeq returns the position of the entry with seq
+func (l *fakeLog) bySeq(seq int64) int {
+	return slices.IndexFunc(l.rows, func(row db.ListAuthLogChainRow) bool { return row.Seq.Int64 == seq })
+}
+
+// rehash recomputes the hashes from seq on, as an attacker with database
+// access but without the signing key can
+func (l *fakeLog) rehash(seq int64) {
+	prevHash := GenesisHash
+	if i := l.bySeq(seq - 1); i >= 0 {
+		prevHash = l.rows[i].EntryHash.String
+	}
+	for ; ; seq++ {
+		i := l.bySeq(seq)
+		if i < 0 {
+			break
+		}
+		row := l.rows[i]
+		hash := HashEntry(prevHash, newEntry(seq, row.ID, row.UserID, row.Action, row.IpAddress, row.UserAgent, row.Timestamp, row.Metadata))
+		l.rows[i].PrevHash = pgtype.Text{String: prevHash, Valid: true}
+		l.rows[i].EntryHash = pgtype.Text{String: hash, Valid: true}
+		prevHash = hash
+		l.head = &db.GetAuditChainHeadRow{Seq: seq, EntryHash: hash}
+	}
+}
+
+// remove deletes the entries with the given seqs
+func (l *fakeLog) remove(seqs ...int64) {
+	l.rows = slices.DeleteFunc(l.rows, func(row db.ListAuthLogChainRow) bool { return slices.Contains(seqs, row.Seq.Int64) })
+}
+
+func testKey(n byte) ed25519.PrivateKey {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = n
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+// newTestChain writes entries 1 to 5 with a checkpoint at 3
+func newTestChain(t *testing.T, key ed25519.PrivateKey) *fakeLog {
+	t.Helper()
+	ctx := context.Background()
+	l := newFakeLog()
+	chain := NewChain(l.db, key)
+	if err := chain.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		err := chain.Append(ctx, Event{
+			ID:        fmt.Sprintf("entry-%d", i),
+			UserID:    "user-1",
+			Action:    "LOGIN",
+			IPAddress: "192.0.2.1",
+			UserAgent: "test",
+			Metadata:  []byte(`{"method":"password"}`),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 3 {
+			if ok, err := chain.Checkpoint(ctx); err != nil || !ok {
+				t.Fatalf("checkpoint: %v, %v", ok, err)
+			}
+		}
+	}
+	return l
+}
+
+func TestVerify(t *testing.T) {
+	key := testKey(1)
+	tests := []struct {
+		name   string
+		tamper func(l *fakeLog)
+		// want lists the kind and seq of the expected problems in order
+		want []Problem
+	}{
+		{
+			name:   "untouched",
+			tamper: func(l *fakeLog) {},
+		},
+		{
+			name:   "modified entry",
+			tamper: func(l *fakeLog) { l.rows[l.bySeq(4)].Action = "LOGOUT" },
+			want:   []Problem{{Kind: ProblemModified, Seq: 4}},
+		},
+		{
+			name:   "modified metadata",
+			tamper: func(l *fakeLog) { l.rows[l.bySeq(2)].Metadata = []byte(`{"method":"passkey"}`) },
+			want:   []Problem{{Kind: ProblemModified, Seq: 2}},
+		},
+		{
+			name: "modified and rehashed entry",
+			tamper: func(l *fakeLog) {
+				l.rows[l.bySeq(4)].UserID = pgtype.Text{String: "user-2", Valid: true}
+				l.rehash(4)
+			},
+			// Entries after a checkpoint can be rewritten undetected until
+			// the next checkpoint
+		},
+		{
+			name: "rewritten before a checkpoint",
+			tamper: func(l *fakeLog) {
+				l.rows[l.bySeq(2)].IpAddress = pgtype.Text{String: "198.51.100.7", Valid: true}
+				l.rehash(2)
+			},
+			want: []Problem{{Kind: ProblemCheckpointMismatch, Seq: 3}},
+		},
+		{
+			name: "relinked entry",
+			tamper: func(l *fakeLog) {
+				i := l.bySeq(2)
+				l.rows[i].PrevHash = pgtype.Text{String: GenesisHash, Valid: true}
+				row := l.rows[i]
+				l.rows[i].EntryHash.String = HashEntry(GenesisHash, newEntry(2, row.ID, row.UserID, row.Action, row.IpAddress, row.UserAgent, row.Timestamp, row.Metadata))
+			},
+			want: []Problem{{Kind: ProblemBrokenLink, Seq: 2}, {Kind: ProblemBrokenLink, Seq: 3}},
+		},
+		{
+			name:   "deleted entry",
+			tamper: func(l *fakeLog) { l.remove(2) },
+			want:   []Problem{{Kind: ProblemGap, Seq: 3}},
+		},
+		{
+			name:   "deleted newest entry",
+			tamper: func(l *fakeLog) { l.remove(5) },
+			want:   []Problem{{Kind: ProblemHeadMismatch, Seq: 5}},
+		},
+		{
+			name: "truncated behind a checkpoint",
+			tamper: func(l *fakeLog) {
+				l.remove(3, 4, 5)
+				l.head = &db.GetAuditChainHeadRow{Seq: 2, EntryHash: l.rows[l.bySeq(2)].EntryHash.String}
+			},
+			want: []Problem{{Kind: ProblemTruncated, Seq: 3}},
+		},
+		{
+			name: "inserted entry",
+			tamper: func(l *fakeLog) {
+				l.rows = append(l.rows, db.ListAuthLogChainRow{ID: "forged", Action: "LOGIN",
+					Timestamp: pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}})
+			},
+			want: []Problem{{Kind: ProblemUnchained}},
+		},
+		{
+			name: "inserted entry with a copied seq",
+			tamper: func(l *fakeLog) {
+				row := l.rows[l.bySeq(3)]
+				row.ID = "forged"
+				l.rows = append(l.rows, row)
+			},
+			want: []Problem{{Kind: ProblemGap, Seq: 3}, {Kind: ProblemModified, Seq: 3}},
+		},
+		{
+			name: "forged checkpoint",
+			tamper: func(l *fakeLog) {
+				cp := SignCheckpoint(testKey(2), 5, l.head.EntryHash, time.Now().UTC())
+				l.checkpoints = append(l.checkpoints, db.AuditCheckpoint{Seq: cp.Seq, EntryHash: cp.Hash, KeyID: KeyID(key.Public().(ed25519.PublicKey)),
+					Signature: base64.StdEncoding.EncodeToString(cp.Signature), CreatedAt: pgtype.Timestamp{Time: cp.CreatedAt, Valid: true}})
+			},
+			want: []Problem{{Kind: ProblemBadSignature, Seq: 5}},
+		},
+		{
+			name: "checkpoint moved to another hash",
+			tamper: func(l *fakeLog) {
+				l.checkpoints[0].EntryHash = l.rows[l.bySeq(2)].EntryHash.String
+			},
+			want: []Problem{{Kind: ProblemBadSignature, Seq: 3}},
+		},
+		{
+			// Checkpoints are only as safe as the copy kept outside the
+			// database
+			name:   "checkpoint deleted",
+			tamper: func(l *fakeLog) { l.checkpoints = nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestChain(t, key)
+			tt.tamper(l)
+
+			report, err := Verify(context.Background(), db.New(l.db), key.Public().(ed25519.PublicKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []Problem
+			for _, p := range report.Problems {
+				got = append(got, Problem{Kind: p.Kind, Seq: p.Seq})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("problems: got %v, want %v", got, tt.want)
+			}
+			if report.Valid != (len(tt.want) == 0) || report.ProblemCount != len(tt.want) {
+				t.Errorf("valid %v with %d problems, want %d", report.Valid, report.ProblemCount, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestVerifyOtherKey(t *testing.T) {
+	l := newTestChain(t, testKey(1))
+	report, err := Verify(context.Background(), db.New(l.db), testKey(2).Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || len(report.Problems) != 1 || report.Problems[0].Kind != ProblemBadSignature {
+		t.Errorf("problems: %+v", report.Problems)
+	}
+}
+
+// TestInit links the entries written before the chain existed in timestamp
+// order, and leaves an existing chain alone
+func TestInit(t *testing.T) {
+	ctx := context.Background()
+	key := testKey(1)
+	l := newFakeLog()
+	start := time.Now().UTC().Truncate(time.Microsecond)
+	for i, id := range []string{"c", "a", "b"} {
+		l.rows = append(l.rows, db.ListAuthLogChainRow{ID: id, Action: "LOGIN",
+			Timestamp: pgtype.Timestamp{Time: start.Add(time.Duration([]int{3, 1, 2}[i]) * time.Second), Valid: true}})
+	}
+
+	chain := NewChain(l.db, key)
+	if err := chain.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for seq, id := range map[int64]string{1: "a", 2: "b", 3: "c"} {
+		if i := l.bySeq(seq); i < 0 || l.rows[i].ID != id {
+			t.Errorf("entry %d is not %s", seq, id)
+		}
+	}
+	if l.head == nil || l.head.Seq != 3 || len(l.checkpoints) != 1 {
+		t.Fatalf("head %+v, %d checkpoints", l.head, len(l.checkpoints))
+	}
+
+	l.rows = append(l.rows, db.ListAuthLogChainRow{ID: "late", Action: "LOGIN", Timestamp: pgtype.Timestamp{Time: start, Valid: true}})
+	if err := chain.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if l.head.Seq != 3 {
+		t.Errorf("second Init moved the head to %d", l.head.Seq)
+	}
+	report, err := Verify(ctx, db.New(l.db), chain.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Entries != 3 || report.Problems[0].Kind != ProblemUnchained {
+		t.Errorf("report: %+v", report)
+	}
+}
+
+func TestHashEntry(t *testing.T) {
+	base := Entry{
+		Seq:       7,
+		ID:        "entry",
+		UserID:    "user",
+		HasUser:   true,
+		Action:    "LOGIN",
+		IPAddress: "192.0.2.1",
+		UserAgent: "test",
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 123456000, time.UTC),
+		Metadata:  []byte(`{}`),
+	}
+	hash := HashEntry(GenesisHash, base)
+	if hash != HashEntry(GenesisHash, base) {
+		t.Fatal("hash is not deterministic")
+	}
+
+	// Below microseconds and in another zone it is the same instant for
+	// Postgres
+	same := base
+	same.Timestamp = base.Timestamp.Add(999).In(time.FixedZone("", 3600))
+	if HashEntry(GenesisHash, same) != hash {
+		t.Error("hash depends on nanoseconds or the time zone")
+	}
+
+	changes := map[string]func(e *Entry){
+		"seq":               func(e *Entry) { e.Seq++ },
+		"id":                func(e *Entry) { e.ID = "other" },
+		"user":              func(e *Entry) { e.UserID = "other" },
+		"no user":           func(e *Entry) { e.HasUser = false },
+		"action":            func(e *Entry) { e.Action = "LOGOUT" },
+		"ip":                func(e *Entry) { e.IPAddress = "192.0.2.2" },
+		"user agent":        func(e *Entry) { e.UserAgent = "other" },
+		"timestamp":         func(e *Entry) { e.Timestamp = e.Timestamp.Add(time.Microsecond) },
+		"metadata":          func(e *Entry) { e.Metadata = []byte(`{"a":1}`) },
+		"no metadata":       func(e *Entry) { e.Metadata = nil },
+		"shifted fields":    func(e *Entry) { e.UserID, e.Action = "userLOGIN", "" },
+		"shifted to the ua": func(e *Entry) { e.IPAddress, e.UserAgent = "", "192.0.2.1test" },
+	}
+	for name, change := range changes {
+		e := base
+		change(&e)
+		if HashEntry(GenesisHash, e) == hash {
+			t.Errorf("changing the %s keeps the hash", name)
+		}
+	}
+	if HashEntry(hash, base) == hash {
+		t.Error("hash does not depend on the previous hash")
+	}
+}
@@ -0,0 +1,182 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+)
+
+// Event is a new auth_logs entry. IPAddress is a valid IP or empty, which is
+// stored as NULL.
+type Event struct {
+	ID        string
+	UserID    string
+	Action    string
+	IPAddress string
+	UserAgent string
+	// Metadata is JSON or nil
+	Metadata []byte
+}
+
+// Pool is the database a Chain writes to, a *pgxpool.Pool
+type Pool interface {
+	db.DBTX
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Chain appends auth_logs entries to the hash chain and signs checkpoints
+type Chain struct {
+	pool Pool
+	q    *db.Queries
+	key  ed25519.PrivateKey
+}
+
+// NewChain creates a Chain that signs checkpoints with key
+func NewChain(pool Pool, key ed25519.PrivateKey) *Chain {
+	return &Chain{pool: pool, q: db.New(pool), key: key}
+}
+
+// PublicKey returns the key that verifies the chain's checkpoints
+func (c *Chain) PublicKey() ed25519.PublicKey {
+	return c.key.Public().(ed25519.PublicKey)
+}
+
+func newEntry(seq int64, id string, userID pgtype.Text, action string, ip, userAgent pgtype.Text, timestamp pgtype.Timestamp, metadata []byte) Entry {
+	return Entry{
+		Seq:       seq,
+		ID:        id,
+		UserID:    userID.String,
+		HasUser:   userID.Valid,
+		Action:    action,
+		IPAddress: ip.String,
+		UserAgent: userAgent.String,
+		Timestamp: timestamp.Time,
+		Metadata:  metadata,
+	}
+}
+
+// Append writes an entry and links it to the chain head. Appends are
+// serialized by a row lock on the head.
+func (c *Chain) Append(ctx context.Context, ev Event) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := c.q.WithTx(tx)
+
+	head, err := q.GetAuditChainHeadForUpdate(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot lock audit chain head: %w", err)
+	}
+
+	row, err := q.CreateAuthLog(ctx,
+		pgtype.Text{String: ev.ID, Valid: true},
+		pgtype.Text{String: ev.UserID, Valid: ev.UserID != ""},
+		pgtype.Text{String: ev.Action, Valid: true},
+		pgtype.Text{String: ev.IPAddress, Valid: ev.IPAddress != ""},
+		pgtype.Text{String: ev.UserAgent, Valid: true},
+		ev.Metadata,
+	)
+	if err != nil {
+		return err
+	}
+
+	// The entry is hashed as read back, so that verification sees exactly
+	// the same values (timestamp precision, normalized jsonb)
+	entry := newEntry(head.Seq+1, row.ID, row.UserID, row.Action, row.IpAddress, row.UserAgent, row.Timestamp, row.Metadata)
+	if err := c.seal(ctx, q, entry, head.EntryHash); err != nil {
+		return err
+	}
+	if err := q.SetAuditChainHead(ctx, pgtype.Int8{Int64: entry.Seq, Valid: true}, pgtype.Text{String: HashEntry(head.EntryHash, entry), Valid: true}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (c *Chain) seal(ctx context.Context, q *db.Queries, entry Entry, prevHash string) error {
+	return q.SealAuthLog(ctx,
+		pgtype.Text{String: entry.ID, Valid: true},
+		pgtype.Int8{Int64: entry.Seq, Valid: true},
+		pgtype.Text{String: prevHash, Valid: true},
+		pgtype.Text{String: HashEntry(prevHash, entry), Valid: true},
+	)
+}
+
+// Init creates the chain head on first start, linking the entries written
+// before the chain existed in timestamp order. It does nothing once the head
+// exists: entries missing from the chain afterwards are reported by Verify.
+func (c *Chain) Init(ctx context.Context) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := c.q.WithTx(tx)
+
+	if err := q.LockAuditChain(ctx); err != nil {
+		return err
+	}
+	if _, err := q.GetAuditChainHead(ctx); err == nil {
+		return nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	rows, err := q.ListUnchainedAuthLogs(ctx)
+	if err != nil {
+		return err
+	}
+	prevHash := GenesisHash
+	for i, row := range rows {
+		entry := newEntry(int64(i+1), row.ID, row.UserID, row.Action, row.IpAddress, row.UserAgent, row.Timestamp, row.Metadata)
+		if err := c.seal(ctx, q, entry, prevHash); err != nil {
+			return err
+		}
+		prevHash = HashEntry(prevHash, entry)
+	}
+	if err := q.SetAuditChainHead(ctx, pgtype.Int8{Int64: int64(len(rows)), Valid: true}, pgtype.Text{String: prevHash, Valid: true}); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	_, err = c.Checkpoint(ctx)
+	return err
+}
+
+// Checkpoint signs the current chain head unless it is already covered by
+// the latest checkpoint. It reports whether a checkpoint was written.
+func (c *Chain) Checkpoint(ctx context.Context) (bool, error) {
+	head, err := c.q.GetAuditChainHead(ctx)
+	if err != nil {
+		return false, err
+	}
+	if head.Seq == 0 {
+		return false, nil
+	}
+	latest, err := c.q.GetLatestAuditCheckpoint(ctx)
+	if err == nil && latest.Seq >= head.Seq {
+		return false, nil
+	} else if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+
+	cp := SignCheckpoint(c.key, head.Seq, head.EntryHash, time.Now().UTC())
+	err = c.q.CreateAuditCheckpoint(ctx,
+		pgtype.Int8{Int64: cp.Seq, Valid: true},
+		pgtype.Text{String: cp.Hash, Valid: true},
+		pgtype.Text{String: cp.KeyID, Valid: true},
+		pgtype.Text{String: base64.StdEncoding.EncodeToString(cp.Signature), Valid: true},
+		pgtype.Timestamp{Time: cp.CreatedAt, Valid: true},
+	)
+	return err == nil, err
+}
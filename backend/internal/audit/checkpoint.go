@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Checkpoint is a signed statement that the chain had the given head. As
+// long as the signing key is kept outside the database, rewriting any entry
+// up to a checkpoint cannot go unnoticed.
+type Checkpoint struct {
+	Seq       int64
+	Hash      string
+	CreatedAt time.Time
+	KeyID     string
+	Signature []byte
+}
+
+// ParseSigningKey decodes a base64 Ed25519 seed
+func ParseSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("audit signing key must be a base64 encoded %d byte seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// KeyID identifies a checkpoint verification key
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+func checkpointMessage(seq int64, hash string, createdAt time.Time) []byte {
+	return []byte("sauthenserver-audit-checkpoint-v1\n" +
+		strconv.FormatInt(seq, 10) + "\n" +
+		hash + "\n" +
+		createdAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano))
+}
+
+// SignCheckpoint creates a checkpoint of the chain head
+func SignCheckpoint(key ed25519.PrivateKey, seq int64, hash string, createdAt time.Time) Checkpoint {
+	return Checkpoint{
+		Seq:       seq,
+		Hash:      hash,
+		CreatedAt: createdAt,
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(key, checkpointMessage(seq, hash, createdAt)),
+	}
+}
+
+// VerifyCheckpoint reports whether the checkpoint was signed with pub
+func VerifyCheckpoint(pub ed25519.PublicKey, cp Checkpoint) bool {
+	return cp.KeyID == KeyID(pub) && ed25519.Verify(pub, checkpointMessage(cp.Seq, cp.Hash, cp.CreatedAt), cp.Signature)
+}
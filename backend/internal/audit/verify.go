@@ -0,0 +1,157 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/yourusername/skoservice-authenserver/internal/db"
+)
+
+const (
+	// verifyPageSize is the number of entries read per query
+	verifyPageSize = 1000
+	// maxReportedProblems bounds the report, further problems are counted
+	maxReportedProblems = 100
+)
+
+// Problem kinds found by Verify
+const (
+	ProblemGap                = "gap"                 // entries were deleted
+	ProblemBrokenLink         = "broken_link"         // prev_hash does not match the previous entry
+	ProblemModified           = "modified"            // content does not match entry_hash
+	ProblemCheckpointMismatch = "checkpoint_mismatch" // chain was rewritten up to a checkpoint
+	ProblemBadSignature       = "bad_signature"       // checkpoint forged or signed with another key
+	ProblemTruncated          = "truncated"           // newest entries were deleted
+	ProblemHeadMismatch       = "head_mismatch"       // chain head does not match the last entry
+	ProblemUnchained          = "unchained"           // entries were inserted outside the chain
+)
+
+type Problem struct {
+	Kind    string `json:"kind"`
+	Seq     int64  `json:"seq,omitempty"`
+	EntryID string `json:"entry_id,omitempty"`
+	Detail  string `json:"detail"`
+}
+
+// Report is the outcome of Verify
+type Report struct {
+	Valid             bool      `json:"valid"`
+	Entries           int64     `json:"entries"`
+	LastSeq           int64     `json:"last_seq"`
+	LastHash          string    `json:"last_hash"`
+	Checkpoints       int       `json:"checkpoints"`
+	LastCheckpointSeq int64     `json:"last_checkpoint_seq"`
+	KeyID             string    `json:"key_id"`
+	PublicKey         string    `json:"public_key"`
+	ProblemCount      int       `json:"problem_count"`
+	Problems          []Problem `json:"problems"`
+	VerifiedAt        time.Time `json:"verified_at"`
+}
+
+func (r *Report) add(p Problem) {
+	r.ProblemCount++
+	if len(r.Problems) < maxReportedProblems {
+		r.Problems = append(r.Problems, p)
+	}
+}
+
+// Verify walks the whole chain and checks every link, every entry hash and
+// every checkpoint against pub. Problems are collected rather than stopping
+// at the first, so the report shows the extent of any tampering.
+func Verify(ctx context.Context, q *db.Queries, pub ed25519.PublicKey) (*Report, error) {
+	report := &Report{
+		KeyID:      KeyID(pub),
+		PublicKey:  base64.StdEncoding.EncodeToString(pub),
+		Problems:   []Problem{},
+		VerifiedAt: time.Now().UTC(),
+	}
+
+	rows, err := q.ListAuditCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make(map[int64]string, len(rows))
+	for _, row := range rows {
+		signature, _ := base64.StdEncoding.DecodeString(row.Signature)
+		cp := Checkpoint{Seq: row.Seq, Hash: row.EntryHash, CreatedAt: row.CreatedAt.Time, KeyID: row.KeyID, Signature: signature}
+		if !VerifyCheckpoint(pub, cp) {
+			report.add(Problem{Kind: ProblemBadSignature, Seq: cp.Seq, Detail: "checkpoint signature is invalid (key " + cp.KeyID + ")"})
+			continue
+		}
+		checkpoints[cp.Seq] = cp.Hash
+		report.Checkpoints++
+		report.LastCheckpointSeq = max(report.LastCheckpointSeq, cp.Seq)
+	}
+
+	prevHash := GenesisHash
+	for {
+		page, err := q.ListAuthLogChain(ctx,
+			pgtype.Int8{Int64: report.LastSeq, Valid: true},
+			pgtype.Int8{Int64: verifyPageSize, Valid: true},
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range page {
+			entry := newEntry(row.Seq.Int64, row.ID, row.UserID, row.Action, row.IpAddress, row.UserAgent, row.Timestamp, row.Metadata)
+			if entry.Seq != report.LastSeq+1 {
+				report.add(Problem{Kind: ProblemGap, Seq: entry.Seq, EntryID: entry.ID,
+					Detail: fmt.Sprintf("entries %d to %d are missing", report.LastSeq+1, entry.Seq-1)})
+			} else if row.PrevHash.String != prevHash {
+				report.add(Problem{Kind: ProblemBrokenLink, Seq: entry.Seq, EntryID: entry.ID,
+					Detail: "prev_hash does not match the hash of the previous entry"})
+			}
+			if HashEntry(row.PrevHash.String, entry) != row.EntryHash.String {
+				report.add(Problem{Kind: ProblemModified, Seq: entry.Seq, EntryID: entry.ID,
+					Detail: "entry content does not match its hash"})
+			}
+			if hash, ok := checkpoints[entry.Seq]; ok && hash != row.EntryHash.String {
+				report.add(Problem{Kind: ProblemCheckpointMismatch, Seq: entry.Seq, EntryID: entry.ID,
+					Detail: "entry hash differs from the signed checkpoint"})
+			}
+
+			report.Entries++
+			report.LastSeq = entry.Seq
+			prevHash = row.EntryHash.String
+		}
+		if len(page) < verifyPageSize {
+			break
+		}
+	}
+	report.LastHash = prevHash
+
+	if report.LastCheckpointSeq > report.LastSeq {
+		report.add(Problem{Kind: ProblemTruncated, Seq: report.LastCheckpointSeq,
+			Detail: fmt.Sprintf("a checkpoint covers entry %d but the chain ends at %d", report.LastCheckpointSeq, report.LastSeq)})
+	}
+
+	head, err := q.GetAuditChainHead(ctx)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		if report.Entries > 0 {
+			report.add(Problem{Kind: ProblemHeadMismatch, Detail: "chain head is missing"})
+		}
+	case err != nil:
+		return nil, err
+	case head.Seq != report.LastSeq || head.EntryHash != report.LastHash:
+		report.add(Problem{Kind: ProblemHeadMismatch, Seq: head.Seq,
+			Detail: fmt.Sprintf("chain head is entry %d but the chain ends at %d", head.Seq, report.LastSeq)})
+	}
+
+	unchained, err := q.CountUnchainedAuthLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if unchained > 0 {
+		report.add(Problem{Kind: ProblemUnchained, Detail: fmt.Sprintf("%d entries are not part of the chain", unchained)})
+	}
+
+	report.Valid = report.ProblemCount == 0
+	return report, nil
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// KeySize is the length of a symmetric PASETO v2.local key and of the
+// Ed25519 seed behind a v2.public key
+const KeySize = 32
+
+// Purpose is the PASETO purpose a key is used for
+type Purpose string
+
+const (
+	// PurposeLocal keys encrypt tokens (v2.local), only this server can read them
+	PurposeLocal Purpose = "local"
+	// PurposePublic keys sign tokens (v2.public), anyone with the public key
+	// can verify them offline
+	PurposePublic Purpose = "public"
+)
+
+// ParsePurpose validates a purpose name from configuration
+func ParsePurpose(name string) (Purpose, error) {
+	switch Purpose(name) {
+	case PurposeLocal, PurposePublic:
+		return Purpose(name), nil
+	}
+	return "", fmt.Errorf("unknown token purpose %q: must be %q or %q", name, PurposeLocal, PurposePublic)
+}
+
+// Key is a token key identified by the kid written into token footers. A
+// key signs new tokens from ActivatesAt on, until a newer key of the same
+// purpose activates, and keeps verifying tokens until RetiresAt. For public
+// keys Secret is the Ed25519 seed.
+type Key struct {
+	ID          string
+	Purpose     Purpose
+	Secret      []byte
+	ActivatesAt time.Time
+	RetiresAt   time.Time // zero while the key has no successor
+}
+
+// PrivateKey returns the Ed25519 signing key of a public purpose key
+func (k Key) PrivateKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(k.Secret)
+}
+
+// PublicKey returns the Ed25519 verification key of a public purpose key
+func (k Key) PublicKey() ed25519.PublicKey {
+	return k.PrivateKey().Public().(ed25519.PublicKey)
+}
+
+// Retired reports whether the key may no longer verify tokens at t
+func (k Key) Retired(t time.Time) bool {
+	return !k.RetiresAt.IsZero() && !t.Before(k.RetiresAt)
+}
+
+// KeyID derives a stable kid from key material, so that every instance
+// started with the same secret agrees on its ID. Public keys are identified
+// by their public half, which is what verifiers see.
+func KeyID(purpose Purpose, secret []byte) string {
+	material := secret
+	if purpose == PurposePublic {
+		material = ed25519.NewKeyFromSeed(secret).Public().(ed25519.PublicKey)
+	}
+	sum := sha256.Sum256(material)
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewKey wraps existing key material
+func NewKey(purpose Purpose, secret []byte, activatesAt time.Time) Key {
+	return Key{ID: KeyID(purpose, secret), Purpose: purpose, Secret: secret, ActivatesAt: activatesAt}
+}
+
+// GenerateKey creates a new random key that activates at activatesAt
+func GenerateKey(purpose Purpose, activatesAt time.Time) (Key, error) {
+	secret := make([]byte, KeySize)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return NewKey(purpose, secret, activatesAt), nil
+}
+
+// DeriveSubkey derives a KeySize key for one use of a master secret with
+// HKDF-SHA256, so instances sharing the master agree on it. Every use has
+// its own label: a subkey leaking from one use exposes neither the master
+// nor the keys of other uses.
+func DeriveSubkey(master []byte, label string) []byte {
+	key, err := hkdf.Key(sha256.New, master, nil, "sauthenserver:"+label, KeySize)
+	if err != nil {
+		// Only lengths beyond 255 hash blocks are rejected
+		panic(err)
+	}
+	return key
+}
+
+// Keyring holds the keys a TokenMaker signs and verifies with. It is safe
+// for concurrent use and can be replaced at runtime when keys rotate.
+type Keyring struct {
+	mu   sync.RWMutex
+	keys []Key // sorted by ActivatesAt, newest first
+}
+
+// NewKeyring creates a keyring from the given keys
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	ring := &Keyring{}
+	if err := ring.Replace(keys); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// Replace swaps the whole key set, e.g. after keys were reloaded from storage
+func (ring *Keyring) Replace(keys []Key) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("keyring needs at least one key")
+	}
+	sorted := make([]Key, len(keys))
+	copy(sorted, keys)
+	for _, key := range sorted {
+		if len(key.Secret) != KeySize {
+			return fmt.Errorf("invalid size for key %s: must be exactly %d bytes", key.ID, KeySize)
+		}
+		if _, err := ParsePurpose(string(key.Purpose)); err != nil {
+			return fmt.Errorf("key %s: %w", key.ID, err)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ActivatesAt.After(sorted[j].ActivatesAt)
+	})
+
+	ring.mu.Lock()
+	ring.keys = sorted
+	ring.mu.Unlock()
+	return nil
+}
+
+// SigningKey returns the newest key of the purpose that is active at t
+func (ring *Keyring) SigningKey(purpose Purpose, t time.Time) (Key, error) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	for _, key := range ring.keys {
+		if key.Purpose == purpose && !key.ActivatesAt.After(t) && !key.Retired(t) {
+			return key, nil
+		}
+	}
+	return Key{}, fmt.Errorf("no active %s signing key", purpose)
+}
+
+// VerificationKey returns the key of the purpose with the given kid unless
+// it has retired
+func (ring *Keyring) VerificationKey(purpose Purpose, kid string, t time.Time) (Key, bool) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	for _, key := range ring.keys {
+		if key.Purpose == purpose && key.ID == kid && !key.Retired(t) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Keys returns a snapshot of all keys, newest first
+func (ring *Keyring) Keys() []Key {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	keys := make([]Key, len(ring.keys))
+	copy(keys, ring.keys)
+	return keys
+}
+
+// SealKey encrypts key material with a master key so it can be stored
+// outside the process
+func SealKey(masterKey, secret []byte) (string, error) {
+	aead, err := chacha20poly1305.NewX(masterKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, secret, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// OpenKey decrypts key material sealed with SealKey
+func OpenKey(masterKey []byte, sealed string) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed key too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T, purpose Purpose, activatesAt, retiresAt time.Time) Key {
+	t.Helper()
+	key, err := GenerateKey(purpose, activatesAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.RetiresAt = retiresAt
+	return key
+}
+
+// TestKeyringRotation walks through a rotation: the new key is published at
+// now, starts signing at now+2m and the old key retires at now+17m. A key of
+// the other purpose never signs or verifies in place of them.
+func TestKeyringRotation(t *testing.T) {
+	for _, purpose := range []Purpose{PurposeLocal, PurposePublic} {
+		t.Run(string(purpose), func(t *testing.T) {
+			other := PurposePublic
+			if purpose == PurposePublic {
+				other = PurposeLocal
+			}
+			now := time.Now().UTC()
+			activatesAt := now.Add(2 * time.Minute)
+			retiresAt := activatesAt.Add(15 * time.Minute)
+			old := testKey(t, purpose, time.Unix(0, 0).UTC(), retiresAt)
+			next := testKey(t, purpose, activatesAt, time.Time{})
+			otherKey := testKey(t, other, now, time.Time{})
+
+			ring, err := NewKeyring(old, next, otherKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name        string
+				at          time.Time
+				wantSigning string
+				// wantVerifies lists the kids that still verify
+				wantVerifies []string
+			}{
+				{"before activation", now, old.ID, []string{old.ID, next.ID}},
+				{"at activation", activatesAt, next.ID, []string{old.ID, next.ID}},
+				{"before retirement", retiresAt.Add(-time.Second), next.ID, []string{old.ID, next.ID}},
+				{"at retirement", retiresAt, next.ID, []string{next.ID}},
+			}
+			for _, tt := range tests {
+				signing, err := ring.SigningKey(purpose, tt.at)
+				if err != nil || signing.ID != tt.wantSigning {
+					t.Errorf("%s: signing key: got (%s, %v), want %s", tt.name, signing.ID, err, tt.wantSigning)
+				}
+				for _, key := range []Key{old, next, otherKey} {
+					_, ok := ring.VerificationKey(purpose, key.ID, tt.at)
+					if want := slices.Contains(tt.wantVerifies, key.ID); ok != want {
+						t.Errorf("%s: key %s verifies: got %v, want %v", tt.name, key.ID, ok, want)
+					}
+				}
+			}
+
+			if _, ok := ring.VerificationKey(purpose, "unknown", now); ok {
+				t.Error("unknown kid verifies")
+			}
+		})
+	}
+}
+
+func TestKeyringNoSigningKey(t *testing.T) {
+	now := time.Now().UTC()
+	ring, err := NewKeyring(
+		testKey(t, PurposeLocal, now.Add(time.Minute), time.Time{}),
+		testKey(t, PurposeLocal, now.Add(-time.Hour), now.Add(-time.Minute)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := ring.SigningKey(PurposeLocal, now); err == nil {
+		t.Errorf("got signing key %s, want none: the only active key retired", key.ID)
+	}
+}
+
+func TestKeyringReplace(t *testing.T) {
+	now := time.Now().UTC()
+	first := testKey(t, PurposeLocal, now.Add(-time.Hour), time.Time{})
+	ring, err := NewKeyring(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ring.Replace(nil); err == nil {
+		t.Error("empty key set accepted")
+	}
+	short := first
+	short.Secret = short.Secret[:KeySize-1]
+	if err := ring.Replace([]Key{short}); err == nil {
+		t.Error("short key accepted")
+	}
+	if signing, _ := ring.SigningKey(PurposeLocal, now); signing.ID != first.ID {
+		t.Errorf("a rejected replacement changed the signing key to %s", signing.ID)
+	}
+
+	second := testKey(t, PurposeLocal, now.Add(-time.Minute), time.Time{})
+	if err := ring.Replace([]Key{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	if signing, _ := ring.SigningKey(PurposeLocal, now); signing.ID != second.ID {
+		t.Errorf("signing key: got %s, want the newer key %s", signing.ID, second.ID)
+	}
+	if keys := ring.Keys(); len(keys) != 2 || keys[0].ID != second.ID {
+		t.Errorf("keys are not listed newest first: %v", keys)
+	}
+}
+
+func TestDeriveSubkey(t *testing.T) {
+	master := bytes.Repeat([]byte("m"), KeySize)
+	key := DeriveSubkey(master, "token-local")
+	// Sealed keys stored in the database depend on the derivation never
+	// changing
+	if got, want := hex.EncodeToString(key), "1861c076d176791edaba01473bf64f9ea32b1bbd8f7ee5d76f9fa5ad5a74deda"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if bytes.Equal(key, master) {
+		t.Error("subkey equals the master")
+	}
+	if !bytes.Equal(key, DeriveSubkey(master, "token-local")) {
+		t.Error("derivation is not deterministic")
+	}
+	if bytes.Equal(key, DeriveSubkey(master, "token-seal")) {
+		t.Error("two labels derive the same key")
+	}
+	if bytes.Equal(key, DeriveSubkey(bytes.Repeat([]byte("n"), KeySize), "token-local")) {
+		t.Error("two masters derive the same key")
+	}
+}
+
+func TestSealKey(t *testing.T) {
+	sealKey := bytes.Repeat([]byte("k"), KeySize)
+	secret := []byte("a token key of thirty-two bytes!")
+	sealed, err := SealKey(sealKey, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := OpenKey(sealKey, sealed)
+	if err != nil || !bytes.Equal(opened, secret) {
+		t.Errorf("got (%q, %v), want %q", opened, err, secret)
+	}
+	if _, err := OpenKey(bytes.Repeat([]byte("x"), KeySize), sealed); err == nil {
+		t.Error("opened with another key")
+	}
+	tampered := []byte(sealed)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := OpenKey(sealKey, string(tampered)); err == nil {
+		t.Error("opened a tampered key")
+	}
+}
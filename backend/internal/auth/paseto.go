@@ -5,35 +5,144 @@ import (
 	"time"
 
 	"github.com/o1egl/paseto"
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
 )
 
 type TokenMaker struct {
-	paseto       *paseto.V2
-	symmetricKey []byte
+	paseto  *paseto.V2
+	keyring *Keyring
+	purpose Purpose
 }
 
-func NewTokenMaker(symmetricKey string) (*TokenMaker, error) {
-	if len(symmetricKey) != 32 {
-		return nil, fmt.Errorf("invalid key size: must be exactly 32 characters")
+// NewTokenMaker creates a maker that issues tokens of the given purpose.
+// Tokens of either purpose are accepted as long as their key is known.
+func NewTokenMaker(keyring *Keyring, purpose Purpose) (*TokenMaker, error) {
+	if keyring == nil {
+		return nil, fmt.Errorf("keyring is required")
+	}
+	if _, err := ParsePurpose(string(purpose)); err != nil {
+		return nil, err
 	}
 
 	maker := &TokenMaker{
-		paseto:       paseto.NewV2(),
-		symmetricKey: []byte(symmetricKey),
+		paseto:  paseto.NewV2(),
+		keyring: keyring,
+		purpose: purpose,
 	}
 
 	return maker, nil
 }
 
+// Purpose returns the purpose of the tokens the maker issues
+func (maker *TokenMaker) Purpose() Purpose {
+	return maker.purpose
+}
+
+// Keyring returns the keys the maker signs and verifies with
+func (maker *TokenMaker) Keyring() *Keyring {
+	return maker.keyring
+}
+
+// tokenFooter is the unencrypted footer that tells verifiers which key
+// sealed the token
+type tokenFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// Authentication method references (RFC 8176) recorded in the amr claim
+const (
+	// AMRPassword is a password login
+	AMRPassword = "pwd"
+	// AMRFederated is a login through an external identity provider
+	AMRFederated = "fed"
+	// AMROneTimePassword is a TOTP or recovery code
+	AMROneTimePassword = "otp"
+	// AMRHardwareKey is a passkey or security key
+	AMRHardwareKey = "hwk"
+	// AMREmail is a code or link sent by email (not registered in RFC 8176)
+	AMREmail = "email"
+	// AMRMultiFactor is set when more than one factor was used
+	AMRMultiFactor = "mfa"
+)
+
+// Claims are the user attributes embedded into a new token
+type Claims struct {
+	UserID          string
+	Email           string
+	Roles           []string
+	Permissions     []string
+	ClaimsTruncated bool
+	SessionID       string
+	// PasswordChangeRequired marks tokens of accounts that have to change
+	// their password before using anything else
+	PasswordChangeRequired bool
+	// MFAEnrollmentRequired marks tokens of accounts whose roles or
+	// permissions require a second factor they have not set up yet
+	MFAEnrollmentRequired bool
+	// AuthTime is when the user last proved their identity, which stays
+	// the same when the session is refreshed
+	AuthTime time.Time
+	// AMR lists the authentication methods used at AuthTime
+	AMR []string
+}
+
+// Limit keeps at most max roles and permissions in total, dropping
+// permissions before roles, and marks the claims as truncated if anything
+// was dropped. A max of 0 means no limit.
+func (claims *Claims) Limit(max int) {
+	if max <= 0 || len(claims.Roles)+len(claims.Permissions) <= max {
+		return
+	}
+	claims.ClaimsTruncated = true
+	if len(claims.Roles) >= max {
+		claims.Roles = claims.Roles[:max]
+		claims.Permissions = []string{}
+		return
+	}
+	claims.Permissions = claims.Permissions[:max-len(claims.Roles)]
+}
+
 type Payload struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Email     string    `json:"email"`
-	Roles     []string  `json:"roles"`
+	ID          string   `json:"id"`
+	UserID      string   `json:"user_id"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	// ClaimsTruncated is set when roles or permissions did not fit into the
+	// token; consumers needing the full set should introspect the token
+	ClaimsTruncated bool   `json:"claims_truncated,omitempty"`
+	SessionID       string `json:"session_id,omitempty"`
+	// PasswordChangeRequired is set for accounts that have to change their
+	// password before using anything else
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"`
+	// MFAEnrollmentRequired is set for accounts whose roles require a second
+	// factor they have not set up yet
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+	// AuthTime and AMR tell when and how the user signed in; services can
+	// demand a recent or multi-factor login for sensitive actions
+	AuthTime  time.Time `json:"auth_time"`
+	AMR       []string  `json:"amr,omitempty"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
 
+// AuthenticatedWithin reports whether the user signed in at most maxAge ago.
+// Tokens issued before auth_time was recorded never qualify.
+func (payload *Payload) AuthenticatedWithin(maxAge time.Duration) bool {
+	return !payload.AuthTime.IsZero() && time.Since(payload.AuthTime) <= maxAge
+}
+
+// AuthenticatedWith reports whether method (an AMR* value) was used to sign
+// in
+func (payload *Payload) AuthenticatedWith(method string) bool {
+	for _, m := range payload.AMR {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
 func (payload *Payload) Valid() error {
 	if time.Now().After(payload.ExpiredAt) {
 		return fmt.Errorf("token has expired")
@@ -41,24 +150,73 @@ func (payload *Payload) Valid() error {
 	return nil
 }
 
-func (maker *TokenMaker) CreateToken(userID string, email string, roles []string, duration time.Duration) (string, *Payload, error) {
+func (maker *TokenMaker) CreateToken(claims Claims, duration time.Duration) (string, *Payload, error) {
+	// The ID is what gets recorded when a single token is revoked, so it
+	// has to be unguessable and unique across instances
+	tokenID, err := utils.GenerateID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now().UTC()
 	payload := &Payload{
-		ID:        fmt.Sprintf("%d", time.Now().UnixNano()), // Simple ID
-		UserID:    userID,
-		Email:     email,
-		Roles:     roles,
-		IssuedAt:  time.Now(),
-		ExpiredAt: time.Now().Add(duration),
+		ID:                     tokenID,
+		UserID:                 claims.UserID,
+		Email:                  claims.Email,
+		Roles:                  claims.Roles,
+		Permissions:            claims.Permissions,
+		ClaimsTruncated:        claims.ClaimsTruncated,
+		SessionID:              claims.SessionID,
+		PasswordChangeRequired: claims.PasswordChangeRequired,
+		MFAEnrollmentRequired:  claims.MFAEnrollmentRequired,
+		AuthTime:               claims.AuthTime,
+		AMR:                    claims.AMR,
+		IssuedAt:               now,
+		ExpiredAt:              now.Add(duration),
 	}
 
-	token, err := maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+	key, err := maker.keyring.SigningKey(maker.purpose, now)
+	if err != nil {
+		return "", nil, err
+	}
+
+	footer := tokenFooter{KeyID: key.ID}
+	var token string
+	if maker.purpose == PurposePublic {
+		token, err = maker.paseto.Sign(key.PrivateKey(), payload, footer)
+	} else {
+		token, err = maker.paseto.Encrypt(key.Secret, payload, footer)
+	}
 	return token, payload, err
 }
 
 func (maker *TokenMaker) VerifyToken(token string) (*Payload, error) {
-	payload := &Payload{}
+	version, tokenPurpose, err := paseto.GetTokenInfo(token)
+	if err != nil || version != paseto.Version2 {
+		return nil, fmt.Errorf("invalid token: unsupported token version")
+	}
+	purpose := PurposeLocal
+	if tokenPurpose == paseto.PUBLIC {
+		purpose = PurposePublic
+	}
 
-	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
+	var footer tokenFooter
+	if err := paseto.ParseFooter(token, &footer); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	// The key is looked up by purpose as well so a token can never be
+	// checked against key material meant for the other mode
+	key, ok := maker.keyring.VerificationKey(purpose, footer.KeyID, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("invalid token: unknown or retired key %q", footer.KeyID)
+	}
+
+	payload := &Payload{}
+	if purpose == PurposePublic {
+		err = maker.paseto.Verify(token, key.PublicKey(), payload, nil)
+	} else {
+		err = maker.paseto.Decrypt(token, key.Secret, payload, nil)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
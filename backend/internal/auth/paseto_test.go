@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestVerifyTokenAfterRotation checks that tokens keep verifying with their
+// key after a rotation until it retires
+func TestVerifyTokenAfterRotation(t *testing.T) {
+	for _, purpose := range []Purpose{PurposeLocal, PurposePublic} {
+		t.Run(string(purpose), func(t *testing.T) {
+			testVerifyTokenAfterRotation(t, purpose)
+		})
+	}
+}
+
+func testVerifyTokenAfterRotation(t *testing.T, purpose Purpose) {
+	now := time.Now().UTC()
+	old := testKey(t, purpose, now.Add(-time.Hour), time.Time{})
+	ring, err := NewKeyring(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maker, err := NewTokenMaker(ring, purpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := maker.CreateToken(Claims{UserID: "alice"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next key is active, the old one retires later
+	next := testKey(t, purpose, now.Add(-time.Minute), time.Time{})
+	old.RetiresAt = now.Add(time.Minute)
+	if err := ring.Replace([]Key{old, next}); err != nil {
+		t.Fatal(err)
+	}
+	if payload, err := maker.VerifyToken(token); err != nil || payload.UserID != "alice" {
+		t.Errorf("token of the old key: got (%v, %v), want a token of alice", payload, err)
+	}
+	fresh, _, err := maker.CreateToken(Claims{UserID: "bob"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload, err := maker.VerifyToken(fresh); err != nil || payload.UserID != "bob" {
+		t.Errorf("token of the new key: got (%v, %v), want a token of bob", payload, err)
+	}
+
+	// The old key retired
+	old.RetiresAt = now.Add(-time.Second)
+	if err := ring.Replace([]Key{old, next}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := maker.VerifyToken(token); err == nil {
+		t.Error("token of a retired key verified")
+	}
+	if _, err := maker.VerifyToken(fresh); err != nil {
+		t.Errorf("token of the new key: %v", err)
+	}
+
+	// A token sealed with a key the keyring does not know
+	other, err := NewKeyring(testKey(t, purpose, now.Add(-time.Hour), time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherMaker, _ := NewTokenMaker(other, purpose)
+	foreign, _, err := otherMaker.CreateToken(Claims{UserID: "mallory"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := maker.VerifyToken(foreign); err == nil {
+		t.Error("token of an unknown key verified")
+	}
+}
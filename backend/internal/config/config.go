@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,6 +15,15 @@ type Config struct {
 	// Server
 	Port        string
 	Environment string
+	PublicURL   string
+	FrontendURL string
+
+	// ProxyHeader names the header carrying the client IP; it is only
+	// trusted from TrustedProxies. It should be a single-value header the
+	// proxy overwrites (e.g. X-Real-IP): the first X-Forwarded-For entry is
+	// whatever the client sent
+	ProxyHeader    string
+	TrustedProxies []string
 
 	// Database
 	DatabaseURL string
@@ -31,24 +43,81 @@ type Config struct {
 
 	// Authentication
 	PasetoSecretKey      string
+	PasetoMode           string
 	SessionDuration      time.Duration
+	AccessTokenDuration  time.Duration
 	RefreshTokenDuration time.Duration
+	TokenMaxClaims       int
+
+	// Email verification, password reset and passwordless email login. An
+	// email login code is discarded after EmailLoginMaxAttempts wrong guesses.
+	EmailVerificationTTL  time.Duration
+	RequireVerifiedEmail  bool
+	PasswordResetTTL      time.Duration
+	EmailLoginTTL         time.Duration
+	EmailLoginMaxAttempts int
+
+	// Two-factor authentication. Holders of MFARequiredRoles or
+	// MFARequiredPermissions must enroll before they can use the admin API.
+	MFAIssuer              string
+	MFAChallengeTTL        time.Duration
+	MFARequiredRoles       []string
+	MFARequiredPermissions []string
+	// StepUpMaxAge is how long ago the user may have signed in for
+	// sensitive actions such as role assignment or password change
+	StepUpMaxAge time.Duration
+
+	// WebAuthn relying party. WebAuthnRPID is the domain passkeys are bound
+	// to; WebAuthnOrigins are the origins allowed to run ceremonies.
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+	WebAuthnTimeout time.Duration
 
-	// OAuth
-	OAuthGoogleClientID     string
-	OAuthGoogleClientSecret string
-	OAuthGoogleRedirectURL  string
+	// Deleted users can be restored until they are purged after this period
+	UserDeletionRetention time.Duration
 
-	OAuthGitHubClientID     string
-	OAuthGitHubClientSecret string
-	OAuthGitHubRedirectURL  string
+	// Audit log checkpoints are signed with AuditSigningKey (base64 Ed25519
+	// seed), derived from the PASETO secret when empty
+	AuditSigningKey         string
+	AuditCheckpointInterval time.Duration
+
+	// Brute-force protection of password logins
+	LoginFailureWindow    time.Duration
+	LoginBackoffBase      time.Duration
+	LoginLockoutThreshold int
+	LoginIPThreshold      int
+	LoginLockoutDuration  time.Duration
+
+	// OAuth providers users can sign in with, and how long a login started
+	// at a provider may take
+	OAuthProviders []OAuthProvider
+	OAuthStateTTL  time.Duration
+
+	// Mail
+	MailDriver        string
+	MailFrom          string
+	MailDefaultLocale string
+	MailOutboxDir     string
+	SMTPHost          string
+	SMTPPort          int
+	SMTPUsername      string
+	SMTPPassword      string
 
 	// CORS
 	CORSOrigins string
 
-	// Rate Limiting
-	RateLimitMax      int
-	RateLimitDuration time.Duration
+	// Rate Limiting. RateLimitMax/Duration apply to route groups without a
+	// limit of their own. Every max and duration must be positive.
+	RateLimitBackend            string
+	RateLimitMax                int
+	RateLimitDuration           time.Duration
+	RateLimitAuthMax            int
+	RateLimitAuthDuration       time.Duration
+	RateLimitUserMax            int
+	RateLimitUserDuration       time.Duration
+	RateLimitIntrospectMax      int
+	RateLimitIntrospectDuration time.Duration
 }
 
 // Load reads configuration from environment variables
@@ -56,6 +125,11 @@ func Load() (*Config, error) {
 	cfg := &Config{
 		Port:        getEnv("PORT", "8080"),
 		Environment: getEnv("ENVIRONMENT", "development"),
+		PublicURL:   getEnv("PUBLIC_URL", "http://localhost:8080"),
+		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
+
+		ProxyHeader:    getEnv("PROXY_HEADER", ""),
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
 
 		// Database
 		DatabaseURL: getEnv("DATABASE_URL", ""),
@@ -74,26 +148,97 @@ func Load() (*Config, error) {
 		RedisDB:       getEnvAsInt("REDIS_DB", 0),
 
 		// Authentication
-		PasetoSecretKey:      getEnv("PASETO_SECRET_KEY", ""),
+		PasetoSecretKey:      getEnv("PASETO_SECRET_KEY", os.Getenv("PASETO_KEY")),
+		PasetoMode:           getEnv("PASETO_MODE", "local"),
 		SessionDuration:      getEnvAsDuration("SESSION_DURATION", 24*time.Hour),
+		AccessTokenDuration:  getEnvAsDuration("ACCESS_TOKEN_DURATION", 15*time.Minute),
 		RefreshTokenDuration: getEnvAsDuration("REFRESH_TOKEN_DURATION", 168*time.Hour),
+		TokenMaxClaims:       getEnvAsInt("TOKEN_MAX_CLAIMS", 100),
+
+		// Email verification, password reset and email login
+		EmailVerificationTTL:  getEnvAsDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		RequireVerifiedEmail:  getEnvAsBool("REQUIRE_VERIFIED_EMAIL", false),
+		PasswordResetTTL:      getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour),
+		EmailLoginTTL:         getEnvAsDuration("EMAIL_LOGIN_TTL", 15*time.Minute),
+		EmailLoginMaxAttempts: getEnvAsInt("EMAIL_LOGIN_MAX_ATTEMPTS", 5),
+
+		// Two-factor authentication
+		MFAIssuer:              getEnv("MFA_ISSUER", "SAuthenServer"),
+		MFAChallengeTTL:        getEnvAsDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		MFARequiredRoles:       getEnvAsList("MFA_REQUIRED_ROLES"),
+		MFARequiredPermissions: getEnvAsList("MFA_REQUIRED_PERMISSIONS"),
+		StepUpMaxAge:           getEnvAsDuration("STEP_UP_MAX_AGE", 10*time.Minute),
+
+		// WebAuthn
+		WebAuthnRPID:    getEnv("WEBAUTHN_RP_ID", ""),
+		WebAuthnRPName:  getEnv("WEBAUTHN_RP_NAME", "SAuthenServer"),
+		WebAuthnOrigins: getEnvAsList("WEBAUTHN_ORIGINS"),
+		WebAuthnTimeout: getEnvAsDuration("WEBAUTHN_TIMEOUT", 5*time.Minute),
+
+		UserDeletionRetention: getEnvAsDuration("USER_DELETION_RETENTION", 30*24*time.Hour),
+
+		AuditSigningKey:         getEnv("AUDIT_SIGNING_KEY", ""),
+		AuditCheckpointInterval: getEnvAsDuration("AUDIT_CHECKPOINT_INTERVAL", time.Hour),
+
+		// Brute-force protection
+		LoginFailureWindow:    getEnvAsDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+		LoginBackoffBase:      getEnvAsDuration("LOGIN_BACKOFF_BASE", time.Second),
+		LoginLockoutThreshold: getEnvAsInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LoginIPThreshold:      getEnvAsInt("LOGIN_IP_THRESHOLD", 50),
+		LoginLockoutDuration:  getEnvAsDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
 
 		// OAuth
-		OAuthGoogleClientID:     getEnv("OAUTH_GOOGLE_CLIENT_ID", ""),
-		OAuthGoogleClientSecret: getEnv("OAUTH_GOOGLE_CLIENT_SECRET", ""),
-		OAuthGoogleRedirectURL:  getEnv("OAUTH_GOOGLE_REDIRECT_URL", ""),
+		OAuthStateTTL: getEnvAsDuration("OAUTH_STATE_TTL", 10*time.Minute),
 
-		OAuthGitHubClientID:     getEnv("OAUTH_GITHUB_CLIENT_ID", ""),
-		OAuthGitHubClientSecret: getEnv("OAUTH_GITHUB_CLIENT_SECRET", ""),
-		OAuthGitHubRedirectURL:  getEnv("OAUTH_GITHUB_REDIRECT_URL", ""),
+		// Mail
+		MailDriver:        getEnv("MAIL_DRIVER", "outbox"),
+		MailFrom:          getEnv("MAIL_FROM", "SAuthenServer <no-reply@localhost>"),
+		MailDefaultLocale: getEnv("MAIL_DEFAULT_LOCALE", "en"),
+		MailOutboxDir:     getEnv("MAIL_OUTBOX_DIR", "./outbox"),
+		SMTPHost:          getEnv("SMTP_HOST", ""),
+		SMTPPort:          getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername:      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
 
 		// CORS
 		CORSOrigins: getEnv("CORS_ORIGINS", "http://localhost:3000"),
 
 		// Rate Limiting
-		RateLimitMax:      getEnvAsInt("RATE_LIMIT_MAX", 100),
-		RateLimitDuration: getEnvAsDuration("RATE_LIMIT_DURATION", time.Minute),
+		RateLimitBackend:            getEnv("RATE_LIMIT_BACKEND", "memory"),
+		RateLimitMax:                getEnvAsInt("RATE_LIMIT_MAX", 100),
+		RateLimitDuration:           getEnvAsDuration("RATE_LIMIT_DURATION", time.Minute),
+		RateLimitAuthMax:            getEnvAsInt("RATE_LIMIT_AUTH_MAX", 20),
+		RateLimitAuthDuration:       getEnvAsDuration("RATE_LIMIT_AUTH_DURATION", time.Minute),
+		RateLimitUserMax:            getEnvAsInt("RATE_LIMIT_USER_MAX", 300),
+		RateLimitUserDuration:       getEnvAsDuration("RATE_LIMIT_USER_DURATION", time.Minute),
+		RateLimitIntrospectMax:      getEnvAsInt("RATE_LIMIT_INTROSPECT_MAX", 1000),
+		RateLimitIntrospectDuration: getEnvAsDuration("RATE_LIMIT_INTROSPECT_DURATION", time.Minute),
+	}
+
+	if _, ok := os.LookupEnv("MFA_REQUIRED_ROLES"); !ok {
+		cfg.MFARequiredRoles = []string{"admin"}
+	}
+	if _, ok := os.LookupEnv("MFA_REQUIRED_PERMISSIONS"); !ok {
+		cfg.MFARequiredPermissions = []string{"admin.access"}
+	}
+
+	// Passkeys belong to the frontend unless configured otherwise
+	if len(cfg.WebAuthnOrigins) == 0 {
+		cfg.WebAuthnOrigins = []string{cfg.FrontendURL}
+	}
+	if cfg.WebAuthnRPID == "" {
+		frontend, err := url.Parse(cfg.FrontendURL)
+		if err != nil || frontend.Hostname() == "" {
+			return nil, fmt.Errorf("WEBAUTHN_RP_ID is required when FRONTEND_URL has no host")
+		}
+		cfg.WebAuthnRPID = frontend.Hostname()
+	}
+
+	providers, err := loadOAuthProviders(cfg.FrontendURL)
+	if err != nil {
+		return nil, err
 	}
+	cfg.OAuthProviders = providers
 
 	// Validate required fields
 	if cfg.PasetoSecretKey == "" {
@@ -102,10 +247,116 @@ func Load() (*Config, error) {
 	if len(cfg.PasetoSecretKey) < 32 {
 		return nil, fmt.Errorf("PASETO_SECRET_KEY must be at least 32 bytes")
 	}
+	if cfg.PasetoMode != "local" && cfg.PasetoMode != "public" {
+		return nil, fmt.Errorf("PASETO_MODE must be either local or public")
+	}
+	rateLimits := []struct {
+		name   string
+		max    int
+		period time.Duration
+	}{
+		{"RATE_LIMIT", cfg.RateLimitMax, cfg.RateLimitDuration},
+		{"RATE_LIMIT_AUTH", cfg.RateLimitAuthMax, cfg.RateLimitAuthDuration},
+		{"RATE_LIMIT_USER", cfg.RateLimitUserMax, cfg.RateLimitUserDuration},
+		{"RATE_LIMIT_INTROSPECT", cfg.RateLimitIntrospectMax, cfg.RateLimitIntrospectDuration},
+	}
+	for _, limit := range rateLimits {
+		if limit.max <= 0 {
+			return nil, fmt.Errorf("%s_MAX must be positive", limit.name)
+		}
+		if limit.period <= 0 {
+			return nil, fmt.Errorf("%s_DURATION must be positive", limit.name)
+		}
+	}
 
 	return cfg, nil
 }
 
+// OAuthProvider configures an identity provider, read from
+// OAUTH_<NAME>_* variables
+type OAuthProvider struct {
+	Name         string
+	Type         string // "oidc" or "github"
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	TrustEmail   bool
+	AuthURL      string
+	TokenURL     string
+	APIURL       string
+}
+
+var oauthProviderName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// oauthPresets hold the defaults of well-known providers. The Cloudflare
+// Access issuer depends on the team domain and client ID.
+var oauthPresets = map[string]OAuthProvider{
+	"google":     {Type: "oidc", Issuer: "https://accounts.google.com"},
+	"github":     {Type: "github"},
+	"cloudflare": {Type: "oidc", TrustEmail: true},
+}
+
+// loadOAuthProviders reads the providers listed in OAUTH_PROVIDERS. Without
+// the list, every preset with a client ID is enabled, as before providers
+// were configurable.
+func loadOAuthProviders(frontendURL string) ([]OAuthProvider, error) {
+	names := getEnvAsList("OAUTH_PROVIDERS")
+	if _, ok := os.LookupEnv("OAUTH_PROVIDERS"); !ok {
+		for _, name := range []string{"google", "github", "cloudflare"} {
+			if getEnv(oauthEnvPrefix(name)+"CLIENT_ID", "") != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var providers []OAuthProvider
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if !oauthProviderName.MatchString(name) {
+			return nil, fmt.Errorf("OAUTH_PROVIDERS: invalid provider name %q", name)
+		}
+		prefix := oauthEnvPrefix(name)
+		preset := oauthPresets[name]
+
+		p := OAuthProvider{
+			Name:         name,
+			Type:         getEnv(prefix+"TYPE", preset.Type),
+			Issuer:       getEnv(prefix+"ISSUER", preset.Issuer),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", strings.TrimSuffix(frontendURL, "/")+"/auth/callback/"+name),
+			Scopes:       getEnvAsList(prefix + "SCOPES"),
+			TrustEmail:   getEnvAsBool(prefix+"TRUST_EMAIL", preset.TrustEmail),
+			AuthURL:      getEnv(prefix+"AUTH_URL", ""),
+			TokenURL:     getEnv(prefix+"TOKEN_URL", ""),
+			APIURL:       getEnv(prefix+"API_URL", ""),
+		}
+		if p.Type == "" {
+			p.Type = "oidc"
+		}
+		if name == "cloudflare" && p.Issuer == "" && os.Getenv("CLOUDFLARE_TEAM_DOMAIN") != "" {
+			p.Issuer = fmt.Sprintf("https://%s.cloudflareaccess.com/cdn-cgi/access/sso/oidc/%s", os.Getenv("CLOUDFLARE_TEAM_DOMAIN"), p.ClientID)
+		}
+
+		if p.ClientID == "" {
+			return nil, fmt.Errorf("%sCLIENT_ID is required", prefix)
+		}
+		if p.Type == "oidc" && p.Issuer == "" {
+			return nil, fmt.Errorf("%sISSUER is required", prefix)
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// oauthEnvPrefix returns the prefix of a provider's variables, e.g.
+// OAUTH_MICROSOFT_ENTRA_ for microsoft-entra
+func oauthEnvPrefix(name string) string {
+	return "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -130,3 +381,22 @@ func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -13,11 +13,11 @@ import (
 
 const createAuthLog = `-- name: CreateAuthLog :one
 INSERT INTO authenserver_service.auth_logs (
-    id, user_id, action, ip_address, user_agent, timestamp
+    id, user_id, action, ip_address, user_agent, timestamp, metadata
 ) VALUES (
-    $1, $2, $3, $4, $5, NOW()
+    $1, $2, $3, $4, $5, NOW(), $6
 )
-RETURNING id, user_id, action, ip_address, user_agent, timestamp
+RETURNING id, user_id, action, ip_address, user_agent, timestamp, metadata
 `
 
 type CreateAuthLogRow struct {
@@ -27,15 +27,17 @@ type CreateAuthLogRow struct {
 	IpAddress pgtype.Text      `json:"ip_address"`
 	UserAgent pgtype.Text      `json:"user_agent"`
 	Timestamp pgtype.Timestamp `json:"timestamp"`
+	Metadata  []byte           `json:"metadata"`
 }
 
-func (q *Queries) CreateAuthLog(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Text, column5 pgtype.Text) (CreateAuthLogRow, error) {
+func (q *Queries) CreateAuthLog(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Text, column5 pgtype.Text, column6 []byte) (CreateAuthLogRow, error) {
 	row := q.db.QueryRow(ctx, createAuthLog,
 		column1,
 		column2,
 		column3,
 		column4,
 		column5,
+		column6,
 	)
 	var i CreateAuthLogRow
 	err := row.Scan(
@@ -45,6 +47,7 @@ func (q *Queries) CreateAuthLog(ctx context.Context, column1 pgtype.Text, column
 		&i.IpAddress,
 		&i.UserAgent,
 		&i.Timestamp,
+		&i.Metadata,
 	)
 	return i, err
 }
@@ -135,3 +138,68 @@ func (q *Queries) GetRecentAuthLogs(ctx context.Context, column1 pgtype.Int8, co
 	}
 	return items, nil
 }
+
+const listAuditLogs = `-- name: ListAuditLogs :many
+SELECT id, user_id, action, ip_address, user_agent, timestamp, metadata
+FROM authenserver_service.auth_logs
+WHERE ($1::text IS NULL OR user_id = $1)
+  AND ($2::text[] IS NULL OR action = ANY($2::text[]))
+  AND ($3::text IS NULL OR CASE WHEN pg_input_is_valid(ip_address, 'inet')
+    THEN ip_address::inet <<= $3::text::cidr END)
+  AND ($4::timestamp IS NULL OR timestamp >= $4)
+  AND ($5::timestamp IS NULL OR timestamp < $5)
+  AND ($6::jsonb IS NULL OR metadata @> $6::jsonb)
+  AND ($7::timestamp IS NULL OR (timestamp, id) < ($7::timestamp, $8::text))
+ORDER BY timestamp DESC, id DESC
+LIMIT $9
+`
+
+type ListAuditLogsRow struct {
+	ID        string           `json:"id"`
+	UserID    pgtype.Text      `json:"user_id"`
+	Action    string           `json:"action"`
+	IpAddress pgtype.Text      `json:"ip_address"`
+	UserAgent pgtype.Text      `json:"user_agent"`
+	Timestamp pgtype.Timestamp `json:"timestamp"`
+	Metadata  []byte           `json:"metadata"`
+}
+
+// Every filter is optional (NULL). Pages are ordered newest first and
+// continue after the (timestamp, id) of the last row of the previous page.
+func (q *Queries) ListAuditLogs(ctx context.Context, column1 pgtype.Text, column2 []string, column3 pgtype.Text, column4 pgtype.Timestamp, column5 pgtype.Timestamp, column6 []byte, column7 pgtype.Timestamp, column8 pgtype.Text, column9 pgtype.Int8) ([]ListAuditLogsRow, error) {
+	rows, err := q.db.Query(ctx, listAuditLogs,
+		column1,
+		column2,
+		column3,
+		column4,
+		column5,
+		column6,
+		column7,
+		column8,
+		column9,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAuditLogsRow{}
+	for rows.Next() {
+		var i ListAuditLogsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Action,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.Timestamp,
+			&i.Metadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
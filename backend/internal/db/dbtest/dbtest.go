@@ -0,0 +1,196 @@
+// Package dbtest stands in for the database in tests. Queries of package db
+// are answered by handlers registered under their sqlc name, so a test only
+// implements the queries the code under test runs.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Handler answers a query called with args. :one queries return the row (a
+// struct whose fields are scanned in order, or a single value) or nil for no
+// rows; :many queries a slice of rows; :exec and :execrows the number of
+// affected rows as an int64, or nil.
+type Handler func(args ...any) (any, error)
+
+// DB implements db.DBTX, Begin and Close like a *pgxpool.Pool. Transactions
+// share the handlers and a rollback undoes nothing.
+type DB struct {
+	mu       sync.Mutex
+	handlers map[string]Handler
+}
+
+// New creates a DB without handlers
+func New() *DB {
+	return &DB{handlers: map[string]Handler{}}
+}
+
+// Handle registers the handler of the query with the given sqlc name,
+// replacing any previous one
+func (d *DB) Handle(name string, h Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[name] = h
+}
+
+func (d *DB) call(sql string, args []any) (any, error) {
+	name := queryName(sql)
+	d.mu.Lock()
+	h, ok := d.handlers[name]
+	d.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("dbtest: no handler for query %q", name)
+	}
+	return h(args...)
+}
+
+// queryName returns X of the "-- name: X :kind" line sqlc puts first
+func queryName(sql string) string {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(sql), "-- name:"))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func (d *DB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	result, err := d.call(sql, args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	var n int64
+	switch v := result.(type) {
+	case nil:
+	case int64:
+		n = v
+	case int:
+		n = int64(v)
+	default:
+		return pgconn.CommandTag{}, fmt.Errorf("dbtest: %s returned %T instead of a row count", queryName(sql), result)
+	}
+	return pgconn.NewCommandTag(fmt.Sprintf("EXEC %d", n)), nil
+}
+
+func (d *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	result, err := d.call(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	r := &rows{}
+	if result != nil {
+		r.items = reflect.ValueOf(result)
+		if r.items.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("dbtest: %s returned %T instead of a slice", queryName(sql), result)
+		}
+	}
+	return r, nil
+}
+
+func (d *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	result, err := d.call(sql, args)
+	if err == nil && result == nil {
+		err = pgx.ErrNoRows
+	}
+	return &row{value: result, err: err}
+}
+
+// Begin starts a transaction on the same handlers
+func (d *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return &tx{db: d}, nil
+}
+
+// Close does nothing
+func (d *DB) Close() {}
+
+type row struct {
+	value any
+	err   error
+}
+
+func (r *row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	return scan(r.value, dest)
+}
+
+type rows struct {
+	items reflect.Value
+	next  int
+}
+
+func (r *rows) Close()                                       {}
+func (r *rows) Err() error                                   { return nil }
+func (r *rows) CommandTag() pgconn.CommandTag                { return pgconn.NewCommandTag("SELECT") }
+func (r *rows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *rows) RawValues() [][]byte                          { return nil }
+func (r *rows) Conn() *pgx.Conn                              { return nil }
+
+func (r *rows) Next() bool {
+	if !r.items.IsValid() || r.next >= r.items.Len() {
+		return false
+	}
+	r.next++
+	return true
+}
+
+func (r *rows) Scan(dest ...any) error {
+	return scan(r.items.Index(r.next-1).Interface(), dest)
+}
+
+func (r *rows) Values() ([]any, error) {
+	return nil, fmt.Errorf("dbtest: Values is not supported")
+}
+
+// scan copies value into dest: as a whole if it fits a single destination,
+// field by field otherwise
+func scan(value any, dest []any) error {
+	v := reflect.ValueOf(value)
+	if len(dest) == 1 {
+		if d := reflect.ValueOf(dest[0]).Elem(); v.Type().AssignableTo(d.Type()) {
+			d.Set(v)
+			return nil
+		}
+	}
+	if v.Kind() != reflect.Struct || v.NumField() != len(dest) {
+		return fmt.Errorf("dbtest: cannot scan %T into %d columns", value, len(dest))
+	}
+	for i := range dest {
+		d := reflect.ValueOf(dest[i]).Elem()
+		if !v.Field(i).Type().AssignableTo(d.Type()) {
+			return fmt.Errorf("dbtest: cannot scan field %s of %T into %s", v.Type().Field(i).Name, value, d.Type())
+		}
+		d.Set(v.Field(i))
+	}
+	return nil
+}
+
+// tx is a transaction of DB. The methods the queries do not use are left to
+// the nil pgx.Tx and panic.
+type tx struct {
+	pgx.Tx
+	db *DB
+}
+
+func (t *tx) Begin(ctx context.Context) (pgx.Tx, error) { return t, nil }
+func (t *tx) Commit(ctx context.Context) error          { return nil }
+func (t *tx) Rollback(ctx context.Context) error        { return nil }
+
+func (t *tx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return t.db.Exec(ctx, sql, args...)
+}
+
+func (t *tx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return t.db.Query(ctx, sql, args...)
+}
+
+func (t *tx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return t.db.QueryRow(ctx, sql, args...)
+}
@@ -24,6 +24,21 @@ type Account struct {
 	CreatedAt         pgtype.Timestamp `json:"created_at"`
 }
 
+type AuditChainHead struct {
+	ID        int32            `json:"id"`
+	Seq       int64            `json:"seq"`
+	EntryHash string           `json:"entry_hash"`
+	UpdatedAt pgtype.Timestamp `json:"updated_at"`
+}
+
+type AuditCheckpoint struct {
+	Seq       int64            `json:"seq"`
+	EntryHash string           `json:"entry_hash"`
+	KeyID     string           `json:"key_id"`
+	Signature string           `json:"signature"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
 type AuthLog struct {
 	ID        string           `json:"id"`
 	UserID    pgtype.Text      `json:"user_id"`
@@ -32,6 +47,24 @@ type AuthLog struct {
 	UserAgent pgtype.Text      `json:"user_agent"`
 	Timestamp pgtype.Timestamp `json:"timestamp"`
 	Metadata  []byte           `json:"metadata"`
+	Seq       pgtype.Int8      `json:"seq"`
+	PrevHash  pgtype.Text      `json:"prev_hash"`
+	EntryHash pgtype.Text      `json:"entry_hash"`
+}
+
+type LoginFailure struct {
+	AttemptKey    string           `json:"attempt_key"`
+	Failures      int32            `json:"failures"`
+	LastFailureAt pgtype.Timestamp `json:"last_failure_at"`
+	BlockedUntil  pgtype.Timestamp `json:"blocked_until"`
+}
+
+type MfaRecoveryCode struct {
+	ID        int32            `json:"id"`
+	UserID    string           `json:"user_id"`
+	CodeHash  string           `json:"code_hash"`
+	UsedAt    pgtype.Timestamp `json:"used_at"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
 }
 
 type Permission struct {
@@ -41,6 +74,13 @@ type Permission struct {
 	CreatedAt   pgtype.Timestamp `json:"created_at"`
 }
 
+type RevokedToken struct {
+	TokenID   string           `json:"token_id"`
+	UserID    pgtype.Text      `json:"user_id"`
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+	RevokedAt pgtype.Timestamp `json:"revoked_at"`
+}
+
 type Role struct {
 	ID          int32            `json:"id"`
 	Name        string           `json:"name"`
@@ -54,23 +94,58 @@ type RolePermission struct {
 	PermissionID int32 `json:"permission_id"`
 }
 
+type ServiceClient struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	SecretHash string           `json:"secret_hash"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+}
+
 type Session struct {
 	ID           string           `json:"id"`
 	SessionToken string           `json:"session_token"`
 	UserID       string           `json:"user_id"`
 	Expires      pgtype.Timestamp `json:"expires"`
 	CreatedAt    pgtype.Timestamp `json:"created_at"`
+	FamilyID     pgtype.Text      `json:"family_id"`
+	RotatedAt    pgtype.Timestamp `json:"rotated_at"`
+	RevokedAt    pgtype.Timestamp `json:"revoked_at"`
+	AuthTime     pgtype.Timestamp `json:"auth_time"`
+	Amr          []string         `json:"amr"`
+}
+
+type TokenKey struct {
+	ID          string           `json:"id"`
+	Secret      string           `json:"secret"`
+	ActivatesAt pgtype.Timestamp `json:"activates_at"`
+	RetiresAt   pgtype.Timestamp `json:"retires_at"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	Purpose     string           `json:"purpose"`
 }
 
 type User struct {
-	ID            string           `json:"id"`
-	Name          pgtype.Text      `json:"name"`
-	Email         pgtype.Text      `json:"email"`
-	EmailVerified pgtype.Timestamp `json:"email_verified"`
-	Image         pgtype.Text      `json:"image"`
-	Password      pgtype.Text      `json:"password"`
-	CreatedAt     pgtype.Timestamp `json:"created_at"`
-	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+	ID                 string           `json:"id"`
+	Name               pgtype.Text      `json:"name"`
+	Email              pgtype.Text      `json:"email"`
+	EmailVerified      pgtype.Timestamp `json:"email_verified"`
+	Image              pgtype.Text      `json:"image"`
+	Password           pgtype.Text      `json:"password"`
+	CreatedAt          pgtype.Timestamp `json:"created_at"`
+	UpdatedAt          pgtype.Timestamp `json:"updated_at"`
+	TokensValidAfter   pgtype.Timestamp `json:"tokens_valid_after"`
+	MustChangePassword bool             `json:"must_change_password"`
+	DeletedAt          pgtype.Timestamp `json:"deleted_at"`
+	Status             string           `json:"status"`
+	StatusReason       pgtype.Text      `json:"status_reason"`
+	StatusExpiresAt    pgtype.Timestamp `json:"status_expires_at"`
+}
+
+type UserMfa struct {
+	UserID       string           `json:"user_id"`
+	TotpSecret   string           `json:"totp_secret"`
+	ConfirmedAt  pgtype.Timestamp `json:"confirmed_at"`
+	LastUsedStep int64            `json:"last_used_step"`
+	CreatedAt    pgtype.Timestamp `json:"created_at"`
 }
 
 type UserRole struct {
@@ -83,4 +158,29 @@ type VerificationToken struct {
 	Identifier string           `json:"identifier"`
 	Token      string           `json:"token"`
 	Expires    pgtype.Timestamp `json:"expires"`
+	Attempts   int32            `json:"attempts"`
+}
+
+type WebauthnCredential struct {
+	ID              string           `json:"id"`
+	UserID          string           `json:"user_id"`
+	CredentialID    []byte           `json:"credential_id"`
+	PublicKey       []byte           `json:"public_key"`
+	AttestationType string           `json:"attestation_type"`
+	Transports      []string         `json:"transports"`
+	Aaguid          []byte           `json:"aaguid"`
+	SignCount       int64            `json:"sign_count"`
+	BackupEligible  bool             `json:"backup_eligible"`
+	BackupState     bool             `json:"backup_state"`
+	Name            string           `json:"name"`
+	CreatedAt       pgtype.Timestamp `json:"created_at"`
+	LastUsedAt      pgtype.Timestamp `json:"last_used_at"`
+}
+
+type WebauthnSession struct {
+	ID        string           `json:"id"`
+	UserID    pgtype.Text      `json:"user_id"`
+	Ceremony  string           `json:"ceremony"`
+	Data      []byte           `json:"data"`
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
 }
@@ -11,36 +11,103 @@ import (
 )
 
 type Querier interface {
+	AddVerificationAttempt(ctx context.Context, dollar_1 pgtype.Text) (int32, error)
 	AssignRoleToUser(ctx context.Context, column1 pgtype.Text, column2 pgtype.Int4) error
+	BlockLoginAttempts(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp) error
+	ClearLoginFailures(ctx context.Context, dollar_1 pgtype.Text) error
+	ConfirmUserMFA(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp, column3 pgtype.Int8) (int64, error)
+	ConsumeVerificationToken(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text) (ConsumeVerificationTokenRow, error)
+	ConsumeWebAuthnSession(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text) (ConsumeWebAuthnSessionRow, error)
+	CountUnchainedAuthLogs(ctx context.Context) (int64, error)
+	CountUnusedRecoveryCodes(ctx context.Context, dollar_1 pgtype.Text) (int64, error)
 	CountUsers(ctx context.Context) (pgtype.Int8, error)
+	CountWebAuthnCredentials(ctx context.Context, dollar_1 pgtype.Text) (int64, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (CreateAccountRow, error)
-	CreateAuthLog(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Text, column5 pgtype.Text) (CreateAuthLogRow, error)
+	CreateAuditCheckpoint(ctx context.Context, column1 pgtype.Int8, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Text, column5 pgtype.Timestamp) error
+	CreateAuthLog(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Text, column5 pgtype.Text, column6 []byte) (CreateAuthLogRow, error)
+	CreateRecoveryCode(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text) error
+	CreateRefreshSession(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Timestamp, column5 pgtype.Text, column6 pgtype.Timestamp, column7 []string) (CreateRefreshSessionRow, error)
 	CreateRole(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text) (CreateRoleRow, error)
+	CreateServiceClient(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text) (CreateServiceClientRow, error)
 	CreateSession(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Timestamp) (CreateSessionRow, error)
+	CreateTokenKey(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Timestamp) error
 	CreateUser(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Timestamp, column5 pgtype.Text, column6 pgtype.Text) (CreateUserRow, error)
+	CreateVerificationToken(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Timestamp) error
+	CreateWebAuthnCredential(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 []byte, column4 []byte, column5 pgtype.Text, column6 []string, column7 []byte, column8 pgtype.Int8, column9 pgtype.Bool, column10 pgtype.Bool, column11 pgtype.Text) (WebauthnCredential, error)
+	CreateWebAuthnSession(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 []byte, column5 pgtype.Timestamp) error
 	DeleteAccount(ctx context.Context, dollar_1 pgtype.Text) error
+	DeleteExpiredRevokedTokens(ctx context.Context) error
 	DeleteExpiredSessions(ctx context.Context) error
+	DeleteExpiredVerificationTokens(ctx context.Context) error
+	DeleteExpiredWebAuthnSessions(ctx context.Context, dollar_1 pgtype.Timestamp) error
+	DeleteRecoveryCodes(ctx context.Context, dollar_1 pgtype.Text) error
+	DeleteRetiredTokenKeys(ctx context.Context) error
+	DeleteServiceClient(ctx context.Context, dollar_1 pgtype.Text) error
 	DeleteSession(ctx context.Context, dollar_1 pgtype.Text) error
+	DeleteStaleLoginFailures(ctx context.Context, column1 pgtype.Timestamp, column2 pgtype.Timestamp) error
 	DeleteUser(ctx context.Context, dollar_1 pgtype.Text) error
+	DeleteUserMFA(ctx context.Context, dollar_1 pgtype.Text) error
 	DeleteUserSessions(ctx context.Context, dollar_1 pgtype.Text) error
+	DeleteUserWebAuthnCredentials(ctx context.Context, dollar_1 pgtype.Text) error
+	DeleteVerificationTokens(ctx context.Context, dollar_1 pgtype.Text) error
+	DeleteWebAuthnCredential(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text) (int64, error)
 	GetAccountByProvider(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text) (GetAccountByProviderRow, error)
+	GetAuditChainHead(ctx context.Context) (GetAuditChainHeadRow, error)
+	GetAuditChainHeadForUpdate(ctx context.Context) (GetAuditChainHeadForUpdateRow, error)
 	GetAuthLogsByUser(ctx context.Context, column1 pgtype.Text, column2 pgtype.Int8, column3 pgtype.Int8) ([]GetAuthLogsByUserRow, error)
+	GetLatestAuditCheckpoint(ctx context.Context) (AuditCheckpoint, error)
+	GetLoginBlockedUntil(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Timestamp) (pgtype.Timestamp, error)
 	GetRecentAuthLogs(ctx context.Context, column1 pgtype.Int8, column2 pgtype.Int8) ([]GetRecentAuthLogsRow, error)
+	GetRefreshSessionForUpdate(ctx context.Context, dollar_1 pgtype.Text) (GetRefreshSessionForUpdateRow, error)
 	GetRoleByID(ctx context.Context, dollar_1 pgtype.Int4) (GetRoleByIDRow, error)
 	GetRoleByName(ctx context.Context, dollar_1 pgtype.Text) (GetRoleByNameRow, error)
 	GetRolePermissions(ctx context.Context, dollar_1 pgtype.Int4) ([]GetRolePermissionsRow, error)
+	GetServiceClient(ctx context.Context, dollar_1 pgtype.Text) (GetServiceClientRow, error)
 	GetSessionByToken(ctx context.Context, dollar_1 pgtype.Text) (GetSessionByTokenRow, error)
 	GetUserAccounts(ctx context.Context, dollar_1 pgtype.Text) ([]GetUserAccountsRow, error)
 	GetUserByEmail(ctx context.Context, dollar_1 pgtype.Text) (GetUserByEmailRow, error)
 	GetUserByID(ctx context.Context, dollar_1 pgtype.Text) (GetUserByIDRow, error)
+	GetUserMFA(ctx context.Context, dollar_1 pgtype.Text) (UserMfa, error)
 	GetUserPermissions(ctx context.Context, dollar_1 pgtype.Text) ([]GetUserPermissionsRow, error)
 	GetUserRoles(ctx context.Context, dollar_1 pgtype.Text) ([]GetUserRolesRow, error)
+	GetVerificationToken(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text) (GetVerificationTokenRow, error)
+	IsTokenRevoked(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Timestamp, column4 pgtype.Timestamp) (pgtype.Bool, error)
+	ListAuditCheckpoints(ctx context.Context) ([]AuditCheckpoint, error)
+	// Every filter is optional (NULL). Pages are ordered newest first and
+	// continue after the (timestamp, id) of the last row of the previous page.
+	ListAuditLogs(ctx context.Context, column1 pgtype.Text, column2 []string, column3 pgtype.Text, column4 pgtype.Timestamp, column5 pgtype.Timestamp, column6 []byte, column7 pgtype.Timestamp, column8 pgtype.Text, column9 pgtype.Int8) ([]ListAuditLogsRow, error)
+	ListAuthLogChain(ctx context.Context, column1 pgtype.Int8, column2 pgtype.Int8) ([]ListAuthLogChainRow, error)
+	ListDeletedUsers(ctx context.Context, column1 pgtype.Int8, column2 pgtype.Int8) ([]ListDeletedUsersRow, error)
 	ListRoles(ctx context.Context) ([]ListRolesRow, error)
+	ListServiceClients(ctx context.Context) ([]ListServiceClientsRow, error)
+	ListTokenKeys(ctx context.Context) ([]ListTokenKeysRow, error)
+	ListUnchainedAuthLogs(ctx context.Context) ([]ListUnchainedAuthLogsRow, error)
 	ListUsers(ctx context.Context, column1 pgtype.Int8, column2 pgtype.Int8) ([]ListUsersRow, error)
+	ListWebAuthnCredentials(ctx context.Context, dollar_1 pgtype.Text) ([]WebauthnCredential, error)
+	LockAuditChain(ctx context.Context) error
+	MarkSessionRotated(ctx context.Context, dollar_1 pgtype.Text) error
+	PurgeDeletedUsers(ctx context.Context, dollar_1 pgtype.Timestamp) error
+	RecordLoginFailure(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp, column3 pgtype.Timestamp) (int32, error)
 	RemoveRoleFromUser(ctx context.Context, column1 pgtype.Text, column2 pgtype.Int4) error
+	RestoreUser(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp) (int64, error)
+	RetireTokenKeys(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp) error
+	RevokeSessionFamily(ctx context.Context, dollar_1 pgtype.Text) error
+	RevokeToken(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Timestamp) error
+	RevokeUserTokens(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp) error
+	SealAuthLog(ctx context.Context, column1 pgtype.Text, column2 pgtype.Int8, column3 pgtype.Text, column4 pgtype.Text) error
+	SetAuditChainHead(ctx context.Context, column1 pgtype.Int8, column2 pgtype.Text) error
+	SetMustChangePassword(ctx context.Context, column1 pgtype.Text, column2 pgtype.Bool) error
+	SetUserStatus(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Timestamp) error
+	SoftDeleteUser(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp) error
+	StartUserMFA(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text) (UserMfa, error)
 	UpdateAccountTokens(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Int8) error
 	UpdateUser(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Timestamp, column5 pgtype.Text) (UpdateUserRow, error)
 	UpdateUserPassword(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text) error
+	// The sign count has to grow with every use, unless the authenticator does
+	// not keep one (always 0); anything else hints at a cloned authenticator
+	UpdateWebAuthnCredentialUsage(ctx context.Context, column1 []byte, column2 pgtype.Int8, column3 pgtype.Bool, column4 pgtype.Timestamp) (int64, error)
+	UseRecoveryCode(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Timestamp) (int64, error)
+	UseTOTPStep(ctx context.Context, column1 pgtype.Text, column2 pgtype.Int8) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
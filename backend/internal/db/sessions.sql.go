@@ -11,6 +11,44 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const createRefreshSession = `-- name: CreateRefreshSession :one
+INSERT INTO authenserver_service.sessions (
+    id, session_token, user_id, expires, family_id, auth_time, amr
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING id, session_token, user_id, expires, family_id
+`
+
+type CreateRefreshSessionRow struct {
+	ID           string           `json:"id"`
+	SessionToken string           `json:"session_token"`
+	UserID       string           `json:"user_id"`
+	Expires      pgtype.Timestamp `json:"expires"`
+	FamilyID     pgtype.Text      `json:"family_id"`
+}
+
+func (q *Queries) CreateRefreshSession(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Timestamp, column5 pgtype.Text, column6 pgtype.Timestamp, column7 []string) (CreateRefreshSessionRow, error) {
+	row := q.db.QueryRow(ctx, createRefreshSession,
+		column1,
+		column2,
+		column3,
+		column4,
+		column5,
+		column6,
+		column7,
+	)
+	var i CreateRefreshSessionRow
+	err := row.Scan(
+		&i.ID,
+		&i.SessionToken,
+		&i.UserID,
+		&i.Expires,
+		&i.FamilyID,
+	)
+	return i, err
+}
+
 const createSession = `-- name: CreateSession :one
 INSERT INTO authenserver_service.sessions (
     id, session_token, user_id, expires
@@ -74,6 +112,43 @@ func (q *Queries) DeleteUserSessions(ctx context.Context, dollar_1 pgtype.Text)
 	return err
 }
 
+const getRefreshSessionForUpdate = `-- name: GetRefreshSessionForUpdate :one
+SELECT id, session_token, user_id, expires, family_id, rotated_at, revoked_at, auth_time, amr
+FROM authenserver_service.sessions
+WHERE session_token = $1
+LIMIT 1
+FOR UPDATE
+`
+
+type GetRefreshSessionForUpdateRow struct {
+	ID           string           `json:"id"`
+	SessionToken string           `json:"session_token"`
+	UserID       string           `json:"user_id"`
+	Expires      pgtype.Timestamp `json:"expires"`
+	FamilyID     pgtype.Text      `json:"family_id"`
+	RotatedAt    pgtype.Timestamp `json:"rotated_at"`
+	RevokedAt    pgtype.Timestamp `json:"revoked_at"`
+	AuthTime     pgtype.Timestamp `json:"auth_time"`
+	Amr          []string         `json:"amr"`
+}
+
+func (q *Queries) GetRefreshSessionForUpdate(ctx context.Context, dollar_1 pgtype.Text) (GetRefreshSessionForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, getRefreshSessionForUpdate, dollar_1)
+	var i GetRefreshSessionForUpdateRow
+	err := row.Scan(
+		&i.ID,
+		&i.SessionToken,
+		&i.UserID,
+		&i.Expires,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.RevokedAt,
+		&i.AuthTime,
+		&i.Amr,
+	)
+	return i, err
+}
+
 const getSessionByToken = `-- name: GetSessionByToken :one
 SELECT id, session_token, user_id, expires
 FROM authenserver_service.sessions
@@ -99,3 +174,25 @@ func (q *Queries) GetSessionByToken(ctx context.Context, dollar_1 pgtype.Text) (
 	)
 	return i, err
 }
+
+const markSessionRotated = `-- name: MarkSessionRotated :exec
+UPDATE authenserver_service.sessions
+SET rotated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkSessionRotated(ctx context.Context, dollar_1 pgtype.Text) error {
+	_, err := q.db.Exec(ctx, markSessionRotated, dollar_1)
+	return err
+}
+
+const revokeSessionFamily = `-- name: RevokeSessionFamily :exec
+UPDATE authenserver_service.sessions
+SET revoked_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeSessionFamily(ctx context.Context, dollar_1 pgtype.Text) error {
+	_, err := q.db.Exec(ctx, revokeSessionFamily, dollar_1)
+	return err
+}
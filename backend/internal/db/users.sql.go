@@ -13,6 +13,7 @@ import (
 
 const countUsers = `-- name: CountUsers :one
 SELECT COUNT(*) FROM authenserver_service.users
+WHERE deleted_at IS NULL
 `
 
 func (q *Queries) CountUsers(ctx context.Context) (pgtype.Int8, error) {
@@ -76,20 +77,24 @@ func (q *Queries) DeleteUser(ctx context.Context, dollar_1 pgtype.Text) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, name, email, email_verified, image, password, created_at, updated_at
+SELECT id, name, email, email_verified, image, password, created_at, updated_at, must_change_password, status, status_reason, status_expires_at
 FROM authenserver_service.users
-WHERE email = $1 LIMIT 1
+WHERE email = $1 AND deleted_at IS NULL LIMIT 1
 `
 
 type GetUserByEmailRow struct {
-	ID            string           `json:"id"`
-	Name          pgtype.Text      `json:"name"`
-	Email         pgtype.Text      `json:"email"`
-	EmailVerified pgtype.Timestamp `json:"email_verified"`
-	Image         pgtype.Text      `json:"image"`
-	Password      pgtype.Text      `json:"password"`
-	CreatedAt     pgtype.Timestamp `json:"created_at"`
-	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+	ID                 string           `json:"id"`
+	Name               pgtype.Text      `json:"name"`
+	Email              pgtype.Text      `json:"email"`
+	EmailVerified      pgtype.Timestamp `json:"email_verified"`
+	Image              pgtype.Text      `json:"image"`
+	Password           pgtype.Text      `json:"password"`
+	CreatedAt          pgtype.Timestamp `json:"created_at"`
+	UpdatedAt          pgtype.Timestamp `json:"updated_at"`
+	MustChangePassword bool             `json:"must_change_password"`
+	Status             string           `json:"status"`
+	StatusReason       pgtype.Text      `json:"status_reason"`
+	StatusExpiresAt    pgtype.Timestamp `json:"status_expires_at"`
 }
 
 func (q *Queries) GetUserByEmail(ctx context.Context, dollar_1 pgtype.Text) (GetUserByEmailRow, error) {
@@ -104,25 +109,34 @@ func (q *Queries) GetUserByEmail(ctx context.Context, dollar_1 pgtype.Text) (Get
 		&i.Password,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.MustChangePassword,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, name, email, email_verified, image, password, created_at, updated_at
+SELECT id, name, email, email_verified, image, password, created_at, updated_at, must_change_password, deleted_at, status, status_reason, status_expires_at
 FROM authenserver_service.users
 WHERE id = $1 LIMIT 1
 `
 
 type GetUserByIDRow struct {
-	ID            string           `json:"id"`
-	Name          pgtype.Text      `json:"name"`
-	Email         pgtype.Text      `json:"email"`
-	EmailVerified pgtype.Timestamp `json:"email_verified"`
-	Image         pgtype.Text      `json:"image"`
-	Password      pgtype.Text      `json:"password"`
-	CreatedAt     pgtype.Timestamp `json:"created_at"`
-	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+	ID                 string           `json:"id"`
+	Name               pgtype.Text      `json:"name"`
+	Email              pgtype.Text      `json:"email"`
+	EmailVerified      pgtype.Timestamp `json:"email_verified"`
+	Image              pgtype.Text      `json:"image"`
+	Password           pgtype.Text      `json:"password"`
+	CreatedAt          pgtype.Timestamp `json:"created_at"`
+	UpdatedAt          pgtype.Timestamp `json:"updated_at"`
+	MustChangePassword bool             `json:"must_change_password"`
+	DeletedAt          pgtype.Timestamp `json:"deleted_at"`
+	Status             string           `json:"status"`
+	StatusReason       pgtype.Text      `json:"status_reason"`
+	StatusExpiresAt    pgtype.Timestamp `json:"status_expires_at"`
 }
 
 func (q *Queries) GetUserByID(ctx context.Context, dollar_1 pgtype.Text) (GetUserByIDRow, error) {
@@ -137,26 +151,75 @@ func (q *Queries) GetUserByID(ctx context.Context, dollar_1 pgtype.Text) (GetUse
 		&i.Password,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.MustChangePassword,
+		&i.DeletedAt,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
 	)
 	return i, err
 }
 
+const listDeletedUsers = `-- name: ListDeletedUsers :many
+SELECT id, name, email, deleted_at
+FROM authenserver_service.users
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+LIMIT $1 OFFSET $2
+`
+
+type ListDeletedUsersRow struct {
+	ID        string           `json:"id"`
+	Name      pgtype.Text      `json:"name"`
+	Email     pgtype.Text      `json:"email"`
+	DeletedAt pgtype.Timestamp `json:"deleted_at"`
+}
+
+func (q *Queries) ListDeletedUsers(ctx context.Context, column1 pgtype.Int8, column2 pgtype.Int8) ([]ListDeletedUsersRow, error) {
+	rows, err := q.db.Query(ctx, listDeletedUsers, column1, column2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListDeletedUsersRow{}
+	for rows.Next() {
+		var i ListDeletedUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUsers = `-- name: ListUsers :many
-SELECT id, name, email, email_verified, image, password, created_at, updated_at
+SELECT id, name, email, email_verified, image, password, created_at, updated_at, status, status_reason, status_expires_at
 FROM authenserver_service.users
+WHERE deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT $1 OFFSET $2
 `
 
 type ListUsersRow struct {
-	ID            string           `json:"id"`
-	Name          pgtype.Text      `json:"name"`
-	Email         pgtype.Text      `json:"email"`
-	EmailVerified pgtype.Timestamp `json:"email_verified"`
-	Image         pgtype.Text      `json:"image"`
-	Password      pgtype.Text      `json:"password"`
-	CreatedAt     pgtype.Timestamp `json:"created_at"`
-	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+	ID              string           `json:"id"`
+	Name            pgtype.Text      `json:"name"`
+	Email           pgtype.Text      `json:"email"`
+	EmailVerified   pgtype.Timestamp `json:"email_verified"`
+	Image           pgtype.Text      `json:"image"`
+	Password        pgtype.Text      `json:"password"`
+	CreatedAt       pgtype.Timestamp `json:"created_at"`
+	UpdatedAt       pgtype.Timestamp `json:"updated_at"`
+	Status          string           `json:"status"`
+	StatusReason    pgtype.Text      `json:"status_reason"`
+	StatusExpiresAt pgtype.Timestamp `json:"status_expires_at"`
 }
 
 func (q *Queries) ListUsers(ctx context.Context, column1 pgtype.Int8, column2 pgtype.Int8) ([]ListUsersRow, error) {
@@ -177,6 +240,9 @@ func (q *Queries) ListUsers(ctx context.Context, column1 pgtype.Int8, column2 pg
 			&i.Password,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusReason,
+			&i.StatusExpiresAt,
 		); err != nil {
 			return nil, err
 		}
@@ -188,6 +254,79 @@ func (q *Queries) ListUsers(ctx context.Context, column1 pgtype.Int8, column2 pg
 	return items, nil
 }
 
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :exec
+DELETE FROM authenserver_service.users
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, dollar_1 pgtype.Timestamp) error {
+	_, err := q.db.Exec(ctx, purgeDeletedUsers, dollar_1)
+	return err
+}
+
+const restoreUser = `-- name: RestoreUser :execrows
+UPDATE authenserver_service.users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at > $2
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp) (int64, error) {
+	result, err := q.db.Exec(ctx, restoreUser, column1, column2)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :exec
+UPDATE authenserver_service.users
+SET tokens_valid_after = $2
+WHERE id = $1
+`
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp) error {
+	_, err := q.db.Exec(ctx, revokeUserTokens, column1, column2)
+	return err
+}
+
+const setMustChangePassword = `-- name: SetMustChangePassword :exec
+UPDATE authenserver_service.users
+SET must_change_password = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SetMustChangePassword(ctx context.Context, column1 pgtype.Text, column2 pgtype.Bool) error {
+	_, err := q.db.Exec(ctx, setMustChangePassword, column1, column2)
+	return err
+}
+
+const setUserStatus = `-- name: SetUserStatus :exec
+UPDATE authenserver_service.users
+SET status = $2, status_reason = $3, status_expires_at = $4, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SetUserStatus(ctx context.Context, column1 pgtype.Text, column2 pgtype.Text, column3 pgtype.Text, column4 pgtype.Timestamp) error {
+	_, err := q.db.Exec(ctx, setUserStatus,
+		column1,
+		column2,
+		column3,
+		column4,
+	)
+	return err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :exec
+UPDATE authenserver_service.users
+SET deleted_at = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, column1 pgtype.Text, column2 pgtype.Timestamp) error {
+	_, err := q.db.Exec(ctx, softDeleteUser, column1, column2)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE authenserver_service.users
 SET
@@ -235,7 +374,7 @@ func (q *Queries) UpdateUser(ctx context.Context, column1 pgtype.Text, column2 p
 
 const updateUserPassword = `-- name: UpdateUserPassword :exec
 UPDATE authenserver_service.users
-SET password = $2, updated_at = NOW()
+SET password = $2, must_change_password = FALSE, updated_at = NOW()
 WHERE id = $1
 `
 
@@ -0,0 +1,114 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+// Message is a rendered email with a plain text and an optional HTML body
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config selects and configures a Mailer
+type Config struct {
+	// Driver is "smtp" or "outbox"
+	Driver string
+	From   string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// OutboxDir is where the outbox driver writes .eml files
+	OutboxDir string
+}
+
+// New creates the Mailer selected by config.Driver
+func New(config Config) (Mailer, error) {
+	if config.From == "" {
+		return nil, fmt.Errorf("mail sender address is required")
+	}
+	switch config.Driver {
+	case "smtp":
+		if config.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP host is required for the smtp mail driver")
+		}
+		return &SMTPMailer{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.From,
+		}, nil
+	case "outbox":
+		return NewOutboxMailer(config.OutboxDir, config.From)
+	}
+	return nil, fmt.Errorf("unknown mail driver %q: must be smtp or outbox", config.Driver)
+}
+
+// buildMIME encodes a message as RFC 5322 multipart/alternative
+func buildMIME(from string, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	messageID, err := utils.GenerateRandomString(24)
+	if err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.TrimSuffix(from[at+1:], ">")
+	}
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", messageID, domain)
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", writer.Boundary())
+
+	parts := []struct{ contentType, body string }{{"text/plain", msg.Text}}
+	if msg.HTML != "" {
+		parts = append(parts, struct{ contentType, body string }{"text/html", msg.HTML})
+	}
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
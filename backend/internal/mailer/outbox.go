@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yourusername/skoservice-authenserver/internal/utils"
+)
+
+// OutboxMailer writes every message to a directory as an .eml file instead of
+// sending it. It is meant for development and tests.
+type OutboxMailer struct {
+	Dir  string
+	From string
+}
+
+// NewOutboxMailer creates the outbox directory if needed
+func NewOutboxMailer(dir, from string) (*OutboxMailer, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("outbox directory is required for the outbox mail driver")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("cannot create mail outbox: %w", err)
+	}
+	return &OutboxMailer{Dir: dir, From: from}, nil
+}
+
+// Send writes msg to <Dir>/<timestamp>-<random>.eml
+func (m *OutboxMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := buildMIME(m.From, msg)
+	if err != nil {
+		return err
+	}
+	suffix, err := utils.GenerateRandomString(8)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), suffix)
+	return os.WriteFile(filepath.Join(m.Dir, name), raw, 0o640)
+}
@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// templateFuncs are available in every template
+var templateFuncs = map[string]interface{}{
+	// hours rounds a duration up to whole hours, for "expires in" notes
+	"hours": func(d time.Duration) int {
+		return int((d + time.Hour - 1) / time.Hour)
+	},
+	// minutes rounds a duration up to whole minutes
+	"minutes": func(d time.Duration) int {
+		return int((d + time.Minute - 1) / time.Minute)
+	},
+}
+
+// Templates renders the embedded message templates. Each template lives in
+// templates/<locale>/<name>.tmpl and defines a "subject", a "text" and
+// optionally an "html" block; the html block is rendered with html/template.
+type Templates struct {
+	defaultLocale string
+	text          map[string]*texttemplate.Template // keyed by "<locale>/<name>"
+	html          map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates. Messages in a locale without a
+// variant of the template fall back to defaultLocale.
+func LoadTemplates(defaultLocale string) (*Templates, error) {
+	t := &Templates{
+		defaultLocale: strings.ToLower(defaultLocale),
+		text:          map[string]*texttemplate.Template{},
+		html:          map[string]*htmltemplate.Template{},
+	}
+
+	files, err := fs.Glob(templateFS, "templates/*/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		key := strings.TrimSuffix(strings.TrimPrefix(file, "templates/"), ".tmpl")
+		textTmpl, err := texttemplate.New(path.Base(file)).Funcs(texttemplate.FuncMap(templateFuncs)).ParseFS(templateFS, file)
+		if err != nil {
+			return nil, fmt.Errorf("mail template %s: %w", key, err)
+		}
+		htmlTmpl, err := htmltemplate.New(path.Base(file)).Funcs(htmltemplate.FuncMap(templateFuncs)).ParseFS(templateFS, file)
+		if err != nil {
+			return nil, fmt.Errorf("mail template %s: %w", key, err)
+		}
+		t.text[key] = textTmpl
+		t.html[key] = htmlTmpl
+	}
+
+	if !t.hasLocale(t.defaultLocale) {
+		return nil, fmt.Errorf("no mail templates for default locale %q", defaultLocale)
+	}
+	return t, nil
+}
+
+func (t *Templates) hasLocale(locale string) bool {
+	for key := range t.text {
+		if path.Dir(key) == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Render renders template name for a recipient. locale may be a single tag
+// ("th-TH") or a whole Accept-Language header; the first language with a
+// variant of the template wins, the base language ("th") is tried for
+// regional tags.
+func (t *Templates) Render(name, locale string, data interface{}, to ...string) (Message, error) {
+	key, ok := t.lookup(name, locale)
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	msg := Message{To: to}
+	var buf bytes.Buffer
+	if err := t.text[key].ExecuteTemplate(&buf, "subject", data); err != nil {
+		return Message{}, err
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := t.text[key].ExecuteTemplate(&buf, "text", data); err != nil {
+		return Message{}, err
+	}
+	msg.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	if t.html[key].Lookup("html") != nil {
+		buf.Reset()
+		if err := t.html[key].ExecuteTemplate(&buf, "html", data); err != nil {
+			return Message{}, err
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
+
+func (t *Templates) lookup(name, locale string) (string, bool) {
+	for _, lang := range strings.Split(locale, ",") {
+		lang = strings.ToLower(strings.TrimSpace(strings.SplitN(lang, ";", 2)[0]))
+		if lang == "" {
+			continue
+		}
+		if _, ok := t.text[lang+"/"+name]; ok {
+			return lang + "/" + name, true
+		}
+		base := strings.SplitN(lang, "-", 2)[0]
+		if _, ok := t.text[base+"/"+name]; ok {
+			return base + "/" + name, true
+		}
+	}
+	key := t.defaultLocale + "/" + name
+	_, ok := t.text[key]
+	return key, ok
+}
@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/skoservice-authenserver/internal/auth"
 )
 
 type UserClaims struct {
@@ -11,10 +15,23 @@ type UserClaims struct {
 	Email       string   `json:"email"`
 	Roles       []string `json:"roles"`
 	Permissions []string `json:"permissions"`
+	SessionID   string   `json:"session_id,omitempty"`
 }
 
-// AuthRequired middleware validates PASETO token
-func AuthRequired() fiber.Handler {
+// AuthConfig configures AuthRequired
+type AuthConfig struct {
+	// TokenMaker verifies the bearer token
+	TokenMaker *auth.TokenMaker
+	// IsRevoked is optional and rejects tokens revoked before they expired
+	IsRevoked func(ctx context.Context, payload *auth.Payload) (bool, error)
+	// LoadAccess is optional and fetches roles and permissions for tokens
+	// that were issued with truncated claims
+	LoadAccess func(ctx context.Context, userID string) ([]string, []string, error)
+}
+
+// AuthRequired middleware validates PASETO token. The verified payload is
+// stored in Locals("payload") and the user claims in Locals("user").
+func AuthRequired(config AuthConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get token from Authorization header
 		authHeader := c.Get("Authorization")
@@ -32,17 +49,47 @@ func AuthRequired() fiber.Handler {
 			})
 		}
 
-		token := parts[1]
+		payload, err := config.TokenMaker.VerifyToken(parts[1])
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid token",
+			})
+		}
+
+		ctx := c.UserContext()
+		if config.IsRevoked != nil {
+			revoked, err := config.IsRevoked(ctx, payload)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to validate token",
+				})
+			}
+			if revoked {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Token has been revoked",
+				})
+			}
+		}
 
-		// TODO: Validate PASETO token and extract claims
-		// For now, this is a placeholder
-		_ = token
+		claims := &UserClaims{
+			UserID:      payload.UserID,
+			Email:       payload.Email,
+			Roles:       payload.Roles,
+			Permissions: payload.Permissions,
+			SessionID:   payload.SessionID,
+		}
+		if payload.ClaimsTruncated && config.LoadAccess != nil {
+			claims.Roles, claims.Permissions, err = config.LoadAccess(ctx, payload.UserID)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to load permissions",
+				})
+			}
+		}
 
 		// Store user claims in context
-		c.Locals("user", &UserClaims{
-			UserID: "placeholder-user-id",
-			Email:  "user@example.com",
-		})
+		c.Locals("payload", payload)
+		c.Locals("user", claims)
 
 		return c.Next()
 	}
@@ -105,3 +152,29 @@ func RequireRole(role string) fiber.Handler {
 		return c.Next()
 	}
 }
+
+// RequireRecentAuth middleware requires the user to have signed in at most
+// maxAge ago (the token's auth_time), so that a long-lived session alone
+// cannot be used for sensitive actions. The 401 asks the client to sign the
+// user in again, as in OAuth step-up authentication (RFC 9470).
+func RequireRecentAuth(maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		payload, ok := c.Locals("payload").(*auth.Payload)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Unauthorized",
+			})
+		}
+
+		if !payload.AuthenticatedWithin(maxAge) {
+			maxAgeSeconds := int(maxAge.Seconds())
+			c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="insufficient_user_authentication", max_age=%d`, maxAgeSeconds))
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   "Recent authentication required, sign in again",
+				"max_age": maxAgeSeconds,
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/skoservice-authenserver/internal/ratelimit"
+)
+
+// RateLimitConfig configures RateLimit
+type RateLimitConfig struct {
+	// Name separates the buckets of different route groups
+	Name  string
+	Store ratelimit.Store
+	Limit ratelimit.Limit
+	// KeyFunc identifies the client, KeyByIP when nil
+	KeyFunc func(c *fiber.Ctx) string
+	// Next is optional and skips the limiter when it returns true
+	Next func(c *fiber.Ctx) bool
+}
+
+// KeyByIP limits each client IP
+func KeyByIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
+
+// KeyByUser limits each authenticated user, and falls back to the client IP
+// before authentication. It must run after AuthRequired.
+func KeyByUser(c *fiber.Ctx) string {
+	if user, ok := c.Locals("user").(*UserClaims); ok {
+		return "user:" + user.UserID
+	}
+	return KeyByIP(c)
+}
+
+// RateLimit middleware allows Limit.Max requests per Limit.Period and client,
+// with bursts of up to Limit.Max. It sets the RateLimit-* headers of the IETF
+// draft and Retry-After on rejected requests. Limit.Max and Limit.Period must
+// be positive, which config.Load ensures for the configured limits.
+func RateLimit(config RateLimitConfig) fiber.Handler {
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+	policy := strconv.Itoa(config.Limit.Max) + ";w=" + strconv.Itoa(int(config.Limit.Period.Seconds()))
+
+	return func(c *fiber.Ctx) error {
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+
+		res, err := config.Store.Take(c.UserContext(), config.Name+":"+keyFunc(c), config.Limit)
+		if err != nil {
+			// Fail open: an unavailable store must not take the API down
+			log.Printf("Rate limiter %s failed: %v", config.Name, err)
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Policy", policy)
+		c.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Too many requests",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,137 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// GitHub endpoints, overridden by Config for GitHub Enterprise
+const (
+	gitHubAuthURL  = "https://github.com/login/oauth/authorize"
+	gitHubTokenURL = "https://github.com/login/oauth/access_token"
+	gitHubAPIURL   = "https://api.github.com"
+)
+
+// gitHubProvider signs users in with GitHub, which speaks OAuth 2 but not
+// OIDC: the user is read from the REST API instead of an ID token
+type gitHubProvider struct {
+	config Config
+	oauth2 *oauth2.Config
+	apiURL string
+}
+
+func newGitHubProvider(config Config) *gitHubProvider {
+	endpoint := oauth2.Endpoint{AuthURL: gitHubAuthURL, TokenURL: gitHubTokenURL}
+	if config.AuthURL != "" {
+		endpoint.AuthURL = config.AuthURL
+	}
+	if config.TokenURL != "" {
+		endpoint.TokenURL = config.TokenURL
+	}
+	apiURL := gitHubAPIURL
+	if config.APIURL != "" {
+		apiURL = strings.TrimSuffix(config.APIURL, "/")
+	}
+	scopes := config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email"}
+	}
+
+	return &gitHubProvider{
+		config: config,
+		oauth2: &oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			Endpoint:     endpoint,
+			RedirectURL:  config.RedirectURL,
+			Scopes:       scopes,
+		},
+		apiURL: apiURL,
+	}
+}
+
+func (p *gitHubProvider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL ignores the nonce, which only exists in OIDC
+func (p *gitHubProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	return p.oauth2.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+type gitHubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type gitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func (p *gitHubProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.config.HTTPClient)
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oauth provider %s: code exchange failed: %w", p.config.Name, err)
+	}
+	client := p.oauth2.Client(ctx, token)
+
+	var user gitHubUser
+	if err := p.get(ctx, client, "/user", &user); err != nil {
+		return nil, err
+	}
+	// The profile only shows the public email, which may be unset; the
+	// emails endpoint says which address is primary and verified
+	var emails []gitHubEmail
+	if err := p.get(ctx, client, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject: strconv.FormatInt(user.ID, 10),
+		Name:    user.Name,
+		Picture: user.AvatarURL,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			identity.Email = email.Email
+			return identity, nil
+		}
+	}
+	return nil, ErrNoVerifiedEmail
+}
+
+// get decodes the JSON response of a GitHub API request into v
+func (p *gitHubProvider) get(ctx context.Context, client *http.Client, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oauth provider %s: GET %s failed: %w", p.config.Name, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth provider %s: GET %s returned %s", p.config.Name, path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("oauth provider %s: invalid response from %s: %w", p.config.Name, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package oauth signs users in through external identity providers. OpenID
+// Connect providers (Google, Microsoft Entra, GitLab, Keycloak, Authentik,
+// Cloudflare Access, ...) are configured generically from their discovery
+// document; providers without OIDC, such as GitHub, implement Provider
+// themselves.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Provider types
+const (
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+)
+
+// defaultTimeout bounds requests to identity providers when Config has no
+// HTTPClient
+const defaultTimeout = 10 * time.Second
+
+// ErrNoVerifiedEmail is returned when the identity provider signed the user
+// in but did not vouch for an email address
+var ErrNoVerifiedEmail = errors.New("identity provider returned no verified email")
+
+// Identity is a user as asserted by an identity provider
+type Identity struct {
+	// Subject is the user's ID at the provider
+	Subject string
+	// Email is an address the provider vouches for
+	Email   string
+	Name    string
+	Picture string
+}
+
+// Provider is an external identity provider using the authorization code
+// flow. The caller generates state, nonce and a PKCE verifier per login and
+// passes the same nonce and verifier to Exchange when the browser returns.
+type Provider interface {
+	// Name identifies the provider in routes and audit logs
+	Name() string
+	// AuthCodeURL returns the URL to send the browser to for signing in
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange redeems the authorization code the browser came back with
+	// and returns the signed-in user, or ErrNoVerifiedEmail when the
+	// provider does not vouch for an email address
+	Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error)
+}
+
+// Config configures a provider
+type Config struct {
+	Name string
+	// Type is TypeOIDC (the default) or TypeGitHub
+	Type string
+	// Issuer is the OIDC issuer URL; the discovery document is fetched from
+	// <Issuer>/.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the browser back to
+	RedirectURL string
+	// Scopes default to "openid email profile" for OIDC providers
+	Scopes []string
+	// TrustEmail treats the email address as verified even when the
+	// provider does not send email_verified. Only set it for providers that
+	// never hand out unverified addresses.
+	TrustEmail bool
+	// AuthURL, TokenURL and APIURL override the endpoints of non-OIDC
+	// providers, e.g. for GitHub Enterprise
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+	// HTTPClient makes every request to the provider
+	HTTPClient *http.Client
+}
+
+// New creates the provider described by config. OIDC discovery happens on
+// first use, so an unreachable provider does not keep the server from
+// starting.
+func New(config Config) (Provider, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("oauth provider name is required")
+	}
+	if config.ClientID == "" {
+		return nil, fmt.Errorf("oauth provider %s: client ID is required", config.Name)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	switch config.Type {
+	case "", TypeOIDC:
+		if config.Issuer == "" {
+			return nil, fmt.Errorf("oauth provider %s: issuer is required", config.Name)
+		}
+		return newOIDCProvider(config), nil
+	case TypeGitHub:
+		return newGitHubProvider(config), nil
+	}
+	return nil, fmt.Errorf("oauth provider %s: unknown type %q", config.Name, config.Type)
+}
+
+// flexBool decodes booleans that some providers send as strings
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = flexBool(v == "true")
+	default:
+		*b = false
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	testClientID = "test-client"
+	testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// fakeIdP is an OpenID Connect provider serving discovery, JWKS, token and
+// userinfo endpoints. It remembers the nonce and PKCE challenge of the last
+// authorization request and enforces them like a real provider would.
+type fakeIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	signer jose.Signer
+	key    *rsa.PrivateKey
+
+	nonce     string
+	challenge string
+
+	// idClaims and userinfo are merged into the issued ID token and the
+	// userinfo response
+	idClaims map[string]interface{}
+	userinfo map[string]interface{}
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.RS256,
+		Key:       jose.JSONWebKey{Key: key, KeyID: "test-key"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &fakeIdP{t: t, signer: signer, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/userinfo", idp.userInfo)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *fakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"issuer":                                idp.server.URL,
+		"authorization_endpoint":                idp.server.URL + "/authorize",
+		"token_endpoint":                        idp.server.URL + "/token",
+		"userinfo_endpoint":                     idp.server.URL + "/userinfo",
+		"jwks_uri":                              idp.server.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (idp *fakeIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &idp.key.PublicKey, KeyID: "test-key", Algorithm: "RS256", Use: "sig"},
+	}})
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s256(r.PostForm.Get("code_verifier")) != idp.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := map[string]interface{}{
+		"iss":   idp.server.URL,
+		"aud":   testClientID,
+		"sub":   "user-1",
+		"nonce": idp.nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range idp.idClaims {
+		claims[k] = v
+	}
+	idToken, err := jwt.Signed(idp.signer).Claims(claims).Serialize()
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	writeJSON(w, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (idp *fakeIdP) userInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	info := map[string]interface{}{"sub": "user-1"}
+	for k, v := range idp.userinfo {
+		info[k] = v
+	}
+	writeJSON(w, info)
+}
+
+// authorize plays the browser visiting the authorization URL
+func (idp *fakeIdP) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", q.Get("code_challenge_method"))
+	}
+	idp.nonce, idp.challenge = q.Get("nonce"), q.Get("code_challenge")
+}
+
+func s256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestOIDCProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustEmail bool
+		idClaims   map[string]interface{}
+		userinfo   map[string]interface{}
+		// nonce and verifier passed to Exchange, when not the ones of the
+		// authorization request
+		nonce    string
+		verifier string
+		want     *Identity
+		wantErr  error
+		errMatch string
+	}{
+		{
+			name:     "email in id_token",
+			idClaims: map[string]interface{}{"email": "ann@example.com", "email_verified": true, "name": "Ann"},
+			want:     &Identity{Subject: "user-1", Email: "ann@example.com", Name: "Ann"},
+		},
+		{
+			name:     "email_verified as string",
+			idClaims: map[string]interface{}{"email": "ann@example.com", "email_verified": "true"},
+			want:     &Identity{Subject: "user-1", Email: "ann@example.com"},
+		},
+		{
+			name:     "email from userinfo",
+			userinfo: map[string]interface{}{"email": "ann@example.com", "email_verified": true, "picture": "https://example.com/ann.png"},
+			want:     &Identity{Subject: "user-1", Email: "ann@example.com", Picture: "https://example.com/ann.png"},
+		},
+		{
+			name:       "trusted email without email_verified",
+			trustEmail: true,
+			idClaims:   map[string]interface{}{"email": "ann@example.com"},
+			want:       &Identity{Subject: "user-1", Email: "ann@example.com"},
+		},
+		{
+			name:     "unverified email",
+			idClaims: map[string]interface{}{"email": "ann@example.com", "email_verified": false},
+			wantErr:  ErrNoVerifiedEmail,
+		},
+		{
+			name:    "no email",
+			wantErr: ErrNoVerifiedEmail,
+		},
+		{
+			name:     "userinfo subject mismatch",
+			userinfo: map[string]interface{}{"sub": "user-2", "email": "eve@example.com", "email_verified": true},
+			errMatch: "userinfo subject mismatch",
+		},
+		{
+			name:     "nonce mismatch",
+			idClaims: map[string]interface{}{"email": "ann@example.com", "email_verified": true},
+			nonce:    "other-nonce",
+			errMatch: "nonce mismatch",
+		},
+		{
+			name:     "wrong PKCE verifier",
+			idClaims: map[string]interface{}{"email": "ann@example.com", "email_verified": true},
+			verifier: strings.Repeat("x", 43),
+			errMatch: "code exchange failed",
+		},
+		{
+			name:     "wrong issuer",
+			idClaims: map[string]interface{}{"iss": "https://evil.example.com", "email": "ann@example.com", "email_verified": true},
+			errMatch: "invalid id_token",
+		},
+		{
+			name:     "wrong audience",
+			idClaims: map[string]interface{}{"aud": "other-client", "email": "ann@example.com", "email_verified": true},
+			errMatch: "invalid id_token",
+		},
+		{
+			name:     "expired id_token",
+			idClaims: map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix(), "email": "ann@example.com", "email_verified": true},
+			errMatch: "invalid id_token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newFakeIdP(t)
+			idp.idClaims, idp.userinfo = tt.idClaims, tt.userinfo
+
+			provider, err := New(Config{
+				Name:        "fake",
+				Issuer:      idp.server.URL,
+				ClientID:    testClientID,
+				RedirectURL: "http://localhost:3000/auth/callback/fake",
+				TrustEmail:  tt.trustEmail,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", testVerifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(authURL, idp.server.URL+"/authorize?") {
+				t.Fatalf("AuthCodeURL = %s, want the discovered authorization endpoint", authURL)
+			}
+			idp.authorize(t, authURL)
+
+			nonce, verifier := "nonce-1", testVerifier
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			got, err := provider.Exchange(ctx, "code-1", nonce, verifier)
+			checkExchange(t, got, err, tt.want, tt.wantErr, tt.errMatch)
+		})
+	}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     map[string]interface{}
+		emails   []map[string]interface{}
+		verifier string
+		want     *Identity
+		wantErr  error
+		errMatch string
+	}{
+		{
+			name: "primary verified email",
+			user: map[string]interface{}{"id": 42, "login": "ann", "name": "Ann", "avatar_url": "https://example.com/ann.png"},
+			emails: []map[string]interface{}{
+				{"email": "old@example.com", "primary": false, "verified": true},
+				{"email": "ann@example.com", "primary": true, "verified": true},
+			},
+			want: &Identity{Subject: "42", Email: "ann@example.com", Name: "Ann", Picture: "https://example.com/ann.png"},
+		},
+		{
+			name:   "name falls back to login",
+			user:   map[string]interface{}{"id": 42, "login": "ann"},
+			emails: []map[string]interface{}{{"email": "ann@example.com", "primary": true, "verified": true}},
+			want:   &Identity{Subject: "42", Email: "ann@example.com", Name: "ann"},
+		},
+		{
+			name:    "primary email unverified",
+			user:    map[string]interface{}{"id": 42, "login": "ann"},
+			emails:  []map[string]interface{}{{"email": "ann@example.com", "primary": true, "verified": false}},
+			wantErr: ErrNoVerifiedEmail,
+		},
+		{
+			name:     "wrong PKCE verifier",
+			user:     map[string]interface{}{"id": 42, "login": "ann"},
+			emails:   []map[string]interface{}{{"email": "ann@example.com", "primary": true, "verified": true}},
+			verifier: strings.Repeat("x", 43),
+			errMatch: "code exchange failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var challenge string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				if s256(r.PostForm.Get("code_verifier")) != challenge {
+					w.WriteHeader(http.StatusBadRequest)
+					writeJSON(w, map[string]string{"error": "invalid_grant"})
+					return
+				}
+				writeJSON(w, map[string]string{"access_token": "gh-token", "token_type": "bearer"})
+			})
+			mux.HandleFunc("/api/user", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.user)
+			})
+			mux.HandleFunc("/api/user/emails", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.emails)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			provider, err := New(Config{
+				Name:     "github",
+				Type:     TypeGitHub,
+				ClientID: testClientID,
+				AuthURL:  server.URL + "/login/oauth/authorize",
+				TokenURL: server.URL + "/login/oauth/access_token",
+				APIURL:   server.URL + "/api",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			authURL, err := provider.AuthCodeURL(ctx, "state-1", "", testVerifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, _ := url.Parse(authURL)
+			challenge = u.Query().Get("code_challenge")
+
+			verifier := testVerifier
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			got, err := provider.Exchange(ctx, "code-1", "", verifier)
+			checkExchange(t, got, err, tt.want, tt.wantErr, tt.errMatch)
+		})
+	}
+}
+
+func checkExchange(t *testing.T, got *Identity, err error, want *Identity, wantErr error, errMatch string) {
+	t.Helper()
+	switch {
+	case wantErr != nil:
+		if !errors.Is(err, wantErr) {
+			t.Fatalf("Exchange error = %v, want %v", err, wantErr)
+		}
+	case errMatch != "":
+		if err == nil || !strings.Contains(err.Error(), errMatch) {
+			t.Fatalf("Exchange error = %v, want one containing %q", err, errMatch)
+		}
+	default:
+		if err != nil {
+			t.Fatalf("Exchange error = %v", err)
+		}
+		if *got != *want {
+			t.Fatalf("Exchange = %+v, want %+v", *got, *want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"missing name", Config{ClientID: "c", Issuer: "https://idp.example.com"}},
+		{"missing client ID", Config{Name: "idp", Issuer: "https://idp.example.com"}},
+		{"missing issuer", Config{Name: "idp", ClientID: "c"}},
+		{"unknown type", Config{Name: "idp", ClientID: "c", Type: "saml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.config); err == nil {
+				t.Fatal("New succeeded, want an error")
+			}
+		})
+	}
+}
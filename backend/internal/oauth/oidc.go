@@ -0,0 +1,141 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcProvider is any OpenID Connect provider, configured from its
+// discovery document
+type oidcProvider struct {
+	config Config
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func newOIDCProvider(config Config) *oidcProvider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	return &oidcProvider{config: config}
+}
+
+func (p *oidcProvider) Name() string {
+	return p.config.Name
+}
+
+// clientContext makes the oidc and oauth2 packages use the configured
+// HTTP client
+func (p *oidcProvider) clientContext(ctx context.Context) context.Context {
+	return oidc.ClientContext(ctx, p.config.HTTPClient)
+}
+
+// discover fetches the discovery document once it is first needed. A
+// failed attempt is retried on the next login.
+func (p *oidcProvider) discover(ctx context.Context) (*oauth2.Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider != nil {
+		return p.oauth2, nil
+	}
+
+	provider, err := oidc.NewProvider(p.clientContext(ctx), p.config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oauth provider %s: discovery failed: %w", p.config.Name, err)
+	}
+	p.provider = provider
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.config.RedirectURL,
+		Scopes:       p.config.Scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+	return p.oauth2, nil
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	config, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// oidcClaims are the standard claims read from ID tokens and userinfo
+type oidcClaims struct {
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+	Picture       string   `json:"picture"`
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	config, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = p.clientContext(ctx)
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oauth provider %s: code exchange failed: %w", p.config.Name, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("oauth provider %s: token response has no id_token", p.config.Name)
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("oauth provider %s: invalid id_token: %w", p.config.Name, err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("oauth provider %s: id_token nonce mismatch", p.config.Name)
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oauth provider %s: invalid id_token claims: %w", p.config.Name, err)
+	}
+
+	// Some providers keep the email out of the ID token, userinfo has it
+	if claims.Email == "" && p.provider.UserInfoEndpoint() != "" {
+		info, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("oauth provider %s: userinfo request failed: %w", p.config.Name, err)
+		}
+		var infoClaims oidcClaims
+		if err := info.Claims(&infoClaims); err != nil {
+			return nil, fmt.Errorf("oauth provider %s: invalid userinfo response: %w", p.config.Name, err)
+		}
+		if infoClaims.Subject != idToken.Subject {
+			return nil, fmt.Errorf("oauth provider %s: userinfo subject mismatch", p.config.Name)
+		}
+		claims.Email, claims.EmailVerified = infoClaims.Email, infoClaims.EmailVerified
+		if claims.Name == "" {
+			claims.Name = infoClaims.Name
+		}
+		if claims.Picture == "" {
+			claims.Picture = infoClaims.Picture
+		}
+	}
+
+	if claims.Email == "" || !(bool(claims.EmailVerified) || p.config.TrustEmail) {
+		return nil, ErrNoVerifiedEmail
+	}
+	return &Identity{
+		Subject: idToken.Subject,
+		Email:   claims.Email,
+		Name:    claims.Name,
+		Picture: claims.Picture,
+	}, nil
+}
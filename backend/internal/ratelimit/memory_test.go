@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// elapse moves the last update of the bucket of key d into the past
+func elapse(s *MemoryStore, key string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[key].updated = s.buckets[key].updated.Add(-d)
+}
+
+func take(t *testing.T, s *MemoryStore, key string, limit Limit) Result {
+	t.Helper()
+	res, err := s.Take(context.Background(), key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// TestMemoryStoreBurst empties a full bucket in one burst of Max requests
+func TestMemoryStoreBurst(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Max: 5, Period: time.Minute}
+
+	for i := range limit.Max {
+		res := take(t, s, "a", limit)
+		if !res.Allowed || res.Remaining != limit.Max-1-i {
+			t.Fatalf("request %d: got (allowed %v, remaining %d), want (true, %d)", i+1, res.Allowed, res.Remaining, limit.Max-1-i)
+		}
+	}
+
+	res := take(t, s, "a", limit)
+	if res.Allowed || res.Remaining != 0 {
+		t.Errorf("request over the burst: got (allowed %v, remaining %d), want (false, 0)", res.Allowed, res.Remaining)
+	}
+	// One token refills every Period/Max
+	if want := limit.Period / time.Duration(limit.Max); res.RetryAfter <= 0 || res.RetryAfter > want {
+		t.Errorf("retry after %s, want at most %s", res.RetryAfter, want)
+	}
+	if res.Reset <= limit.Period-time.Second || res.Reset > limit.Period {
+		t.Errorf("reset after %s, want about %s", res.Reset, limit.Period)
+	}
+
+	if res := take(t, s, "b", limit); !res.Allowed {
+		t.Error("the bucket of another key is empty")
+	}
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Max: 4, Period: time.Minute}
+	for range limit.Max {
+		take(t, s, "a", limit)
+	}
+
+	// Half a period refills half the bucket
+	elapse(s, "a", limit.Period/2)
+	for i := range 2 {
+		if res := take(t, s, "a", limit); !res.Allowed {
+			t.Fatalf("request %d after half a period was rejected", i+1)
+		}
+	}
+	if res := take(t, s, "a", limit); res.Allowed {
+		t.Error("took more tokens than refilled")
+	}
+
+	// A long pause refills the bucket, but not beyond Max
+	elapse(s, "a", 10*limit.Period)
+	for i := range limit.Max {
+		if res := take(t, s, "a", limit); !res.Allowed {
+			t.Fatalf("request %d after a long pause was rejected", i+1)
+		}
+	}
+	if res := take(t, s, "a", limit); res.Allowed {
+		t.Error("the bucket refilled beyond Max")
+	}
+}
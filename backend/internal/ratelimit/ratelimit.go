@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limit is a token bucket of Max tokens that refills completely over Period,
+// i.e. Max requests per Period with bursts of up to Max
+type Limit struct {
+	Max    int
+	Period time.Duration
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next token, zero when Allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps the token buckets
+type Store interface {
+	// Take removes a token from the bucket of key
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Config selects and configures a Store
+type Config struct {
+	// Backend is "memory" or "redis"
+	Backend string
+
+	RedisURL      string
+	RedisPassword string
+	RedisDB       int
+}
+
+// New creates the Store selected by config.Backend
+func New(config Config) (Store, error) {
+	switch config.Backend {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "redis":
+		opts, err := redis.ParseURL(config.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: %w", err)
+		}
+		if config.RedisPassword != "" {
+			opts.Password = config.RedisPassword
+		}
+		if config.RedisDB != 0 {
+			opts.DB = config.RedisDB
+		}
+		client := redis.NewClient(opts)
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			return nil, fmt.Errorf("cannot connect to Redis: %w", err)
+		}
+		return NewRedisStore(client), nil
+	}
+	return nil, fmt.Errorf("unknown rate limit backend %q: must be memory or redis", config.Backend)
+}
+
+// result builds the Result for a bucket holding tokens after the take
+func result(allowed bool, tokens float64, limit Limit) Result {
+	rate := float64(limit.Max) / float64(limit.Period)
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Max,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.Max) - tokens) / rate),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / rate)
+	}
+	return res
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes from a bucket stored as a hash of tokens and
+// last update time (ms). The Redis clock is used so that instances with
+// skewed clocks share the same buckets. Tokens are returned as a string
+// because Lua numbers are truncated to integers in replies.
+var takeScript = redis.NewScript(`
+local max = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = max
+	updated = now
+end
+tokens = math.min(max, tokens + math.max(0, now - updated) * max / period)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps token buckets in Redis so that every instance of the
+// server shares the same limits
+type RedisStore struct {
+	client *redis.Client
+	// Prefix namespaces the bucket keys
+	Prefix string
+}
+
+// NewRedisStore creates a RedisStore on client
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client, Prefix: "ratelimit:"}
+}
+
+// Take implements Store
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := takeScript.Run(ctx, s.client, []string{s.Prefix + key}, limit.Max, limit.Period.Milliseconds()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := reply[0].(int64)
+	tokensReply, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(tokensReply, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(allowed == 1, tokens, limit), nil
+}
@@ -0,0 +1,99 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors,
+// "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeRFC6238 checks the SHA1 vectors of RFC 6238 appendix B. The RFC
+// lists 8 digit codes, 6 digit codes are their last 6 digits.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		want := tt.want[len(tt.want)-Digits:]
+		code, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Errorf("T=%d: got %s, want %s", tt.unix, code, want)
+		}
+		// Authenticator apps may show lowercase secrets
+		if code, _ := Code(strings.ToLower(rfcSecret), Step(time.Unix(tt.unix, 0))); code != want {
+			t.Errorf("T=%d lowercase secret: got %s, want %s", tt.unix, code, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	code := func(step int64) string {
+		c, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(step), 1, step, true},
+		{"previous step", code(step - 1), 1, step - 1, true},
+		{"next step", code(step + 1), 1, step + 1, true},
+		{"two steps behind", code(step - 2), 1, 0, false},
+		{"two steps ahead", code(step + 2), 1, 0, false},
+		{"previous step without skew", code(step - 1), 0, 0, false},
+		{"with spaces", code(step)[:3] + " " + code(step)[3:], 1, step, true},
+		{"wrong code", "000000", 1, 0, false},
+		{"too short", code(step)[:5], 1, 0, false},
+		{"too long", code(step) + "0", 1, 0, false},
+		{"empty", "", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("got (%d, %v), want (%d, %v)", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := Validate("not base32!", code(step), now, 1); ok {
+		t.Error("invalid secret accepted a code")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != secretSize {
+		t.Fatalf("secret %q decodes to %d bytes (%v), want %d", secret, len(key), err, secretSize)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Error("two secrets are equal")
+	}
+}
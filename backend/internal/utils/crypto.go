@@ -2,7 +2,9 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 
 	"golang.org/x/crypto/bcrypt"
@@ -40,3 +42,10 @@ func GenerateID() (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
+
+// HashToken returns the SHA-256 digest of an opaque token, hex encoded, so
+// that only the digest needs to be stored and looked up
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
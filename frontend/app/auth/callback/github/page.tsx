@@ -3,16 +3,16 @@
 import { useEffect, useRef } from 'react'; // Added useRef
 import { useRouter, useSearchParams } from 'next/navigation';
 import api from '@/lib/api';
-import { useAuthStore } from '@/store/authStore';
+import { finishLogin } from '@/lib/session';
 
 export default function GitHubCallback() {
   const router = useRouter();
   const searchParams = useSearchParams();
-  const setAuth = useAuthStore((state) => state.setAuth);
   const processedRef = useRef(false); // Ref to track if code has been processed
 
   useEffect(() => {
     const code = searchParams.get('code');
+    const state = searchParams.get('state');
     const error = searchParams.get('error');
 
     if (error) {
@@ -20,7 +20,7 @@ export default function GitHubCallback() {
       return;
     }
 
-    if (!code) {
+    if (!code || !state) {
       router.push('/login?error=no_code');
       return;
     }
@@ -30,9 +30,8 @@ export default function GitHubCallback() {
 
     const exchangeCode = async () => {
       try {
-        const res = await api.post('/auth/oauth/github/callback', { code });
-        setAuth(res.data.user, res.data.access_token);
-        router.push('/dashboard');
+        const res = await api.post('/auth/oauth/github/callback', { code, state });
+        router.push(finishLogin(res.data));
       } catch (err: any) {
         console.error('GitHub login failed:', err);
         const params = new URLSearchParams();
@@ -42,7 +41,7 @@ export default function GitHubCallback() {
     };
 
     exchangeCode();
-  }, [searchParams, router, setAuth]);
+  }, [searchParams, router]);
 
   return (
     <div className="flex min-h-screen items-center justify-center">
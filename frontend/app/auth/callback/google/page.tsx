@@ -3,16 +3,16 @@
 import { useEffect, useRef } from 'react';
 import { useRouter, useSearchParams } from 'next/navigation';
 import api from '@/lib/api';
-import { useAuthStore } from '@/store/authStore';
+import { finishLogin } from '@/lib/session';
 
 export default function GoogleCallback() {
   const router = useRouter();
   const searchParams = useSearchParams();
-  const setAuth = useAuthStore((state) => state.setAuth);
   const processedRef = useRef(false);
 
   useEffect(() => {
     const code = searchParams.get('code');
+    const state = searchParams.get('state');
     const error = searchParams.get('error');
 
     if (error) {
@@ -20,7 +20,7 @@ export default function GoogleCallback() {
       return;
     }
 
-    if (!code) {
+    if (!code || !state) {
       router.push('/login?error=no_code');
       return;
     }
@@ -30,9 +30,8 @@ export default function GoogleCallback() {
 
     const exchangeCode = async () => {
       try {
-        const res = await api.post('/auth/oauth/google/callback', { code });
-        setAuth(res.data.user, res.data.access_token);
-        router.push('/dashboard');
+        const res = await api.post('/auth/oauth/google/callback', { code, state });
+        router.push(finishLogin(res.data));
       } catch (err: any) {
         console.error('Google login failed:', err);
         const params = new URLSearchParams();
@@ -42,7 +41,7 @@ export default function GoogleCallback() {
     };
 
     exchangeCode();
-  }, [searchParams, router, setAuth]);
+  }, [searchParams, router]);
 
   return (
     <div className="flex min-h-screen items-center justify-center">
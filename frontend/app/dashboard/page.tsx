@@ -47,7 +47,12 @@ export default function Dashboard() {
     fetchData();
   }, [token, router]);
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    try {
+      await api.post('/auth/logout', { refresh_token: useAuthStore.getState().refreshToken });
+    } catch (err) {
+      console.error("Failed to revoke session", err);
+    }
     logout();
     router.push('/login');
   };
@@ -0,0 +1,99 @@
+'use client';
+
+import { useEffect, useState } from 'react';
+import { useRouter } from 'next/navigation';
+import api from '@/lib/api';
+import { finishLogin, MFAChallenge, pendingMFAChallenge } from '@/lib/session';
+
+export default function MFALogin() {
+  const router = useRouter();
+  const [challenge, setChallenge] = useState<MFAChallenge | null>(null);
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
+  const [code, setCode] = useState('');
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    const pending = pendingMFAChallenge();
+    if (!pending) {
+      router.push('/login');
+      return;
+    }
+    setChallenge(pending);
+  }, [router]);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!challenge) return;
+    try {
+      const res = await api.post('/auth/mfa/verify', {
+        mfa_token: challenge.mfa_token,
+        ...(useRecoveryCode ? { recovery_code: code } : { code }),
+      });
+      router.push(finishLogin(res.data));
+    } catch (err: any) {
+      setError(err.response?.data?.message || 'Verification failed');
+    }
+  };
+
+  if (!challenge) return null;
+
+  // Recovery codes come with TOTP; accounts with only a passkey have to
+  // complete the challenge through the WebAuthn login endpoints
+  if (!challenge.methods.includes('totp')) {
+    return (
+      <div className="flex min-h-screen items-center justify-center p-24 text-center text-sm text-gray-600 dark:text-gray-400">
+        This account requires a passkey as second factor, sign in with your passkey instead.
+      </div>
+    );
+  }
+
+  return (
+    <div className="flex min-h-screen flex-col items-center justify-center p-24">
+      <div className="w-full max-w-md space-y-8">
+        <div>
+          <h2 className="mt-6 text-center text-3xl font-bold tracking-tight">
+            Two-factor authentication
+          </h2>
+          <p className="mt-2 text-center text-sm text-gray-600 dark:text-gray-400">
+            {useRecoveryCode
+              ? 'Enter one of your recovery codes'
+              : 'Enter the code from your authenticator app'}
+          </p>
+        </div>
+        <form className="mt-8 space-y-6" onSubmit={handleSubmit}>
+          <input
+            name="code"
+            type="text"
+            autoComplete="one-time-code"
+            required
+            className="relative block w-full rounded-md border-0 py-1.5 px-3 text-gray-900 ring-1 ring-inset ring-gray-300 placeholder:text-gray-400 focus:ring-2 focus:ring-inset focus:ring-indigo-600 sm:text-sm sm:leading-6 dark:bg-gray-800 dark:text-white dark:ring-gray-700"
+            placeholder={useRecoveryCode ? 'Recovery code' : '123456'}
+            value={code}
+            onChange={(e) => setCode(e.target.value)}
+          />
+
+          {error && <div className="text-red-500 text-sm text-center">{error}</div>}
+
+          <button
+            type="submit"
+            className="group relative flex w-full justify-center rounded-md bg-indigo-600 px-3 py-2 text-sm font-semibold text-white hover:bg-indigo-500 focus-visible:outline focus-visible:outline-2 focus-visible:outline-offset-2 focus-visible:outline-indigo-600"
+          >
+            Verify
+          </button>
+
+          <button
+            type="button"
+            className="w-full text-center text-sm font-medium text-indigo-600 hover:text-indigo-500"
+            onClick={() => {
+              setUseRecoveryCode(!useRecoveryCode);
+              setCode('');
+              setError('');
+            }}
+          >
+            {useRecoveryCode ? 'Use your authenticator app' : 'Use a recovery code'}
+          </button>
+        </form>
+      </div>
+    </div>
+  );
+}
@@ -4,11 +4,10 @@ import { useState } from 'react';
 import { useRouter } from 'next/navigation';
 import Link from 'next/link';
 import api from '@/lib/api';
-import { useAuthStore } from '@/store/authStore';
+import { finishLogin } from '@/lib/session';
 
 export default function Login() {
   const router = useRouter();
-  const setAuth = useAuthStore((state) => state.setAuth);
   const [formData, setFormData] = useState({
     email: '',
     password: '',
@@ -19,8 +18,7 @@ export default function Login() {
     e.preventDefault();
     try {
       const res = await api.post('/auth/login', formData);
-      setAuth(res.data.user, res.data.access_token);
-      router.push('/dashboard');
+      router.push(finishLogin(res.data));
     } catch (err: any) {
       setError(err.response?.data?.message || 'Login failed');
     }
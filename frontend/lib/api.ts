@@ -1,8 +1,10 @@
-import axios from 'axios';
+import axios, { AxiosError, InternalAxiosRequestConfig } from 'axios';
 import { useAuthStore } from '../store/authStore';
 
+const baseURL = 'http://localhost:8080/api/v1';
+
 const api = axios.create({
-  baseURL: 'http://localhost:8080/api/v1',
+  baseURL,
   headers: {
     'Content-Type': 'application/json',
   },
@@ -19,4 +21,47 @@ api.interceptors.request.use(
   (error) => Promise.reject(error)
 );
 
+// Access tokens are short-lived: on a 401 the refresh token is rotated once
+// and the request retried. Concurrent requests share the same refresh, since
+// replaying a rotated refresh token revokes the whole session.
+let refreshing: Promise<string | null> | null = null;
+
+const refreshAccessToken = async (): Promise<string | null> => {
+  const { refreshToken, setTokens, logout } = useAuthStore.getState();
+  if (!refreshToken) return null;
+  try {
+    const res = await axios.post(`${baseURL}/auth/refresh`, { refresh_token: refreshToken });
+    setTokens(res.data.access_token, res.data.refresh_token);
+    return res.data.access_token;
+  } catch {
+    logout();
+    return null;
+  }
+};
+
+api.interceptors.response.use(
+  (response) => response,
+  async (error: AxiosError<any>) => {
+    const config = error.config as (InternalAxiosRequestConfig & { _retried?: boolean }) | undefined;
+    // Only signed-in requests are retried. A step-up challenge (max_age)
+    // needs a new sign-in, not a refresh.
+    if (
+      error.response?.status !== 401 ||
+      !config?.headers.Authorization ||
+      config._retried ||
+      error.response.data?.max_age !== undefined
+    ) {
+      return Promise.reject(error);
+    }
+
+    refreshing = refreshing ?? refreshAccessToken().finally(() => (refreshing = null));
+    const token = await refreshing;
+    if (!token) return Promise.reject(error);
+
+    config._retried = true;
+    config.headers.Authorization = `Bearer ${token}`;
+    return api(config);
+  }
+);
+
 export default api;
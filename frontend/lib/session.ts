@@ -0,0 +1,32 @@
+import { useAuthStore } from '../store/authStore';
+
+const mfaChallengeKey = 'mfa-challenge';
+
+export interface MFAChallenge {
+  mfa_token: string;
+  mfa_token_expires_at: string;
+  methods: string[];
+}
+
+// finishLogin stores the session of a successful login and returns the page
+// to continue on. Accounts with a second factor get an MFA challenge
+// instead, which /login/mfa completes.
+export function finishLogin(data: any): string {
+  if (data.mfa_required) {
+    const challenge: MFAChallenge = {
+      mfa_token: data.mfa_token,
+      mfa_token_expires_at: data.mfa_token_expires_at,
+      methods: data.methods,
+    };
+    sessionStorage.setItem(mfaChallengeKey, JSON.stringify(challenge));
+    return '/login/mfa';
+  }
+  sessionStorage.removeItem(mfaChallengeKey);
+  useAuthStore.getState().setAuth(data.user, data.access_token, data.refresh_token);
+  return '/dashboard';
+}
+
+export function pendingMFAChallenge(): MFAChallenge | null {
+  const raw = sessionStorage.getItem(mfaChallengeKey);
+  return raw ? JSON.parse(raw) : null;
+}
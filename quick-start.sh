@@ -195,6 +195,12 @@ if [ "$DB_HOST" != "localhost" ] && [ "$DB_HOST" != "127.0.0.1" ]; then
         } || {
             print_warning "Schema may already exist or check failed, continuing..."
         }
+        for migration in backend/db/schema/0*.sql; do
+            case "$migration" in *001_init*) continue ;; esac
+            PGPASSWORD="$DB_PASSWORD" psql -h "$DB_HOST" -p "$DB_PORT" -U "$DB_USER" -d "$DB_NAME" -f "$migration" > /dev/null 2>&1 || {
+                print_warning "Migration $migration failed, continuing..."
+            }
+        done
     else
         print_warning "psql not installed. Please apply schema manually:"
         print_warning "PGPASSWORD=<password> psql -h $DB_HOST -p $DB_PORT -U $DB_USER -d $DB_NAME -f backend/db/schema/001_init_with_migrations.sql"
@@ -204,6 +210,12 @@ else
     docker-compose exec -T postgres psql -U postgres -d skoservice < backend/db/schema/001_init_with_migrations.sql > /dev/null 2>&1 || {
         print_warning "Schema may already exist, continuing..."
     }
+    for migration in backend/db/schema/0*.sql; do
+        case "$migration" in *001_init*) continue ;; esac
+        docker-compose exec -T postgres psql -U postgres -d skoservice < "$migration" > /dev/null 2>&1 || {
+            print_warning "Migration $migration failed, continuing..."
+        }
+    done
     print_success "Database schema applied"
 fi
 